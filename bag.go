@@ -1,11 +1,10 @@
 package otium
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"strings"
-
-	"github.com/peterh/liner"
 )
 
 // Bag is passed to the [RunFn] of [Step]. It contains all the k/v pairs added
@@ -26,8 +25,18 @@ type Variable struct {
 	Name string
 	Desc string
 	Fn   ValidatorFn
-	val  string
-	set  bool
+	// Secret, if true, means that the value must not be disclosed, for
+	// example in the summary report.
+	Secret bool
+	// Values is the optional list of values offered by tab completion. To
+	// also enforce it, use Fn.
+	Values []string
+	// Complete is the optional function returning the values offered by tab
+	// completion for the value being entered, prefix. If set, it takes
+	// precedence over Values.
+	Complete func(prefix string) []string
+	val      string
+	set      bool
 }
 
 // Get returns the value of key if key exists. If key doesn't exist, Get
@@ -62,25 +71,25 @@ type ValidatorFn func(val string) error
 // If the validator function fn(key, val) returns an error, ask shows the
 // error to the user and keeps asking for a value. If fn returns no error,
 // then ask stores the key/value in the bag.
-func (bag *Bag) ask(key string, term *liner.State) (string, error) {
+func (bag *Bag) ask(key string, term Prompter, out io.Writer) (string, error) {
 	variable := bag.bag[key]
 	if variable.set {
 		return variable.val, nil
 	}
 
-	term.SetCompleter(makeInputCompleter(key))
+	term.SetCompleter(makeInputCompleter(key, variable.completions))
 
 	for {
-		fmt.Printf("(input) Enter %s (set %s <value>) or '?' for help\n",
+		fmt.Fprintf(out, "(input) Enter %s (set %s <value>) or '?' for help\n",
 			variable.Desc, key)
 		line, err := term.PromptWithSuggestion(
 			"(input)>> ", "set "+key+" ", -1)
+		if errors.Is(err, ErrPromptAborted) {
+			// Ctrl-C behaves as the back command.
+			return "", errBack
+		}
 		if err != nil {
-			if err == io.EOF {
-				return "", io.EOF
-			}
-			fmt.Println(err)
-			continue
+			return "", fmt.Errorf("reading the input: %w", err)
 		}
 		// TODO should actually also parse to required type here
 		//  (string or int)
@@ -91,7 +100,7 @@ func (bag *Bag) ask(key string, term *liner.State) (string, error) {
 		}
 		switch tokens[0] {
 		case "help", "?":
-			fmt.Print(`
+			fmt.Fprint(out, `
   set <key> <value>    set <key> to <value>
   back                 go back to the top level REPL
 
@@ -101,45 +110,50 @@ func (bag *Bag) ask(key string, term *liner.State) (string, error) {
 			return "", errBack
 		case "set":
 			if len(tokens) != 3 {
-				fmt.Printf("want: set <key> <value>; have: %q\n", tokens)
+				fmt.Fprintf(out, "want: set <key> <value>; have: %q\n", tokens)
 				continue
 			}
 			name, val := tokens[1], tokens[2]
 			if name != key {
-				fmt.Printf("set: wrong key: have %q; want %q\n", name, key)
+				fmt.Fprintf(out, "set: wrong key: have %q; want %q\n", name, key)
 				continue
 			}
 			if variable.Fn != nil {
 				if err := variable.Fn(val); err != nil {
-					fmt.Println(err)
+					fmt.Fprintln(out, err)
 					continue
 				}
 			}
 			bag.Put(key, val)
 			return val, nil
 		default:
-			fmt.Printf("invalid: %q\n", line)
+			fmt.Fprintf(out, "invalid: %q\n", line)
 			continue
 		}
 	}
 }
 
-// makeInputCompleter returns a liner.Completer.
+// makeInputCompleter returns a Completer.
 // We use a closure and a factory as an adapter, since this allows to pass the
-// `key` parameter.
-func makeInputCompleter(key string) liner.Completer {
+// `key` parameter and the function returning the value completions, values.
+func makeInputCompleter(key string, values func(prefix string) []string) Completer {
 	return func(line string) []string {
 		commands := []string{"help", "?", "back", "set"}
 		completions := make([]string, 0, len(commands))
-		line = strings.ToLower(line)
+		lower := strings.ToLower(line)
 		for _, cmd := range commands {
-			if strings.HasPrefix(cmd, line) {
+			if strings.HasPrefix(cmd, lower) {
 				completions = append(completions, cmd)
 			}
 		}
-		// Basic dynamic completer on key name.
-		if strings.HasPrefix(line, "set") {
-			completions = []string{"set " + key + " "}
+		// Basic dynamic completer on key name and values.
+		if strings.HasPrefix(lower, "set") {
+			head := "set " + key + " "
+			completions = []string{head}
+			if strings.HasPrefix(line, head) && values != nil {
+				prefix := line[len(head):]
+				completions = filterPrefix(values(prefix), head, prefix, "")
+			}
 		}
 		//fmt.Printf("completer: %q, completions: %q\n", line, completions)
 		return completions
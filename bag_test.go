@@ -5,7 +5,6 @@ import (
 	"time"
 
 	"github.com/go-quicktest/qt"
-	"github.com/peterh/liner"
 
 	"github.com/marco-m/otium/expect"
 )
@@ -55,28 +54,25 @@ func TestBag_AskKeyAlreadySet(t *testing.T) {
 	sut := NewBag()
 	sut.Put(key, "banana")
 
-	val, err := sut.ask(key, nil)
+	val, err := sut.ask(key, nil, nil)
 
 	qt.Assert(t, qt.IsNil(err))
 	qt.Assert(t, qt.Equals(val, "banana"))
 }
 
 func TestBag_AskKeyInteractive(t *testing.T) {
-	exp, cleanup := expect.NewFilePipe(100*time.Millisecond, expect.MatchMaxDef)
-	defer cleanup()
+	stdin, stdout, exp := expect.New(100*time.Millisecond, expect.MatchMaxDef)
 	const key = "fruit"
 	sut := NewBag()
 	sut.bag[key] = Variable{Name: "key", Desc: "Your fruit for breakfast"}
 
-	term := liner.NewLiner()
-	// Restore terminal to previous mode, super important.
-	defer term.Close()
+	term := NewLinePrompter(stdin, stdout)
 
 	var val string
 	asyncErr := make(chan error)
 	go func() {
 		var err error
-		val, err = sut.ask(key, term)
+		val, err = sut.ask(key, term, stdout)
 		//os.Stdout.Close()
 		asyncErr <- err
 	}()
@@ -96,14 +92,16 @@ func TestBag_AskKeyInteractive(t *testing.T) {
 
 func TestInputCompleter(t *testing.T) {
 	type testCase struct {
-		name string
-		key  string
-		line string
-		want []string
+		name   string
+		key    string
+		values []string
+		line   string
+		want   []string
 	}
 
 	run := func(t *testing.T, tc testCase) {
-		sut := makeInputCompleter(tc.key)
+		values := func(prefix string) []string { return tc.values }
+		sut := makeInputCompleter(tc.key, values)
 
 		have := sut(tc.line)
 
@@ -134,6 +132,20 @@ func TestInputCompleter(t *testing.T) {
 			line: "set",
 			want: []string{"set fruit "},
 		},
+		{
+			name:   "set key expands to all values",
+			key:    "fruit",
+			values: []string{"banana", "mango"},
+			line:   "set fruit ",
+			want:   []string{"set fruit banana", "set fruit mango"},
+		},
+		{
+			name:   "set key expands to matching values",
+			key:    "fruit",
+			values: []string{"banana", "mango"},
+			line:   "set fruit m",
+			want:   []string{"set fruit mango"},
+		},
 	}
 
 	for _, tc := range testCases {
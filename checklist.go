@@ -0,0 +1,154 @@
+package otium
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tick interactively asks the user to acknowledge, one by one, the items of
+// the checklist recorded in rec. Items already acknowledged (for example
+// during a previous visit of the same step) are kept.
+// The user can tick an item (done <n>), tick all the remaining items
+// (done all) or waive an item, giving a reason (waive <n> <reason>).
+// The items are ticked at the time returned by now.
+// tick returns only when all items are acknowledged, or with errBack if the
+// user wants to go back to the top level REPL.
+func tick(rec *StepRecord, now func() time.Time, term Prompter, out io.Writer) error {
+	if len(rec.Checklist) == 0 {
+		return nil
+	}
+
+	term.SetCompleter(makeCheckCompleter(len(rec.Checklist)))
+
+	for {
+		if checklistDone(rec.Checklist) {
+			return nil
+		}
+		printChecklist(out, rec.Checklist)
+		fmt.Fprintf(out, "(check) Tick the items (done <n>, done all, waive <n> <reason>) or '?' for help\n")
+		line, err := term.Prompt("(check)>> ")
+		if errors.Is(err, ErrPromptAborted) {
+			// Ctrl-C behaves as the back command.
+			return errBack
+		}
+		if err != nil {
+			return fmt.Errorf("reading the checklist: %w", err)
+		}
+
+		tokens := strings.Fields(line)
+		if len(tokens) == 0 {
+			continue
+		}
+		switch tokens[0] {
+		case "help", "?":
+			fmt.Fprint(out, `
+  done <n>               tick item <n>
+  done all               tick all the remaining items
+  waive <n> <reason>     waive item <n>, explaining why
+  back                   go back to the top level REPL
+
+`)
+			continue
+		case "back":
+			return errBack
+		case "done":
+			if len(tokens) != 2 {
+				fmt.Fprintf(out, "want: done <n>|all; have: %q\n", tokens)
+				continue
+			}
+			ticked := now()
+			if tokens[1] == "all" {
+				for i := range rec.Checklist {
+					if !rec.Checklist[i].acked() {
+						rec.Checklist[i].tick(ticked)
+					}
+				}
+				continue
+			}
+			i, err := parseItem(tokens[1], len(rec.Checklist))
+			if err != nil {
+				fmt.Fprintln(out, "done:", err)
+				continue
+			}
+			rec.Checklist[i].tick(ticked)
+		case "waive":
+			if len(tokens) < 3 {
+				fmt.Fprintf(out, "want: waive <n> <reason>; have: %q\n", tokens)
+				continue
+			}
+			i, err := parseItem(tokens[1], len(rec.Checklist))
+			if err != nil {
+				fmt.Fprintln(out, "waive:", err)
+				continue
+			}
+			rec.Checklist[i].waive(strings.Join(tokens[2:], " "))
+		default:
+			fmt.Fprintf(out, "invalid: %q\n", line)
+			continue
+		}
+	}
+}
+
+// parseItem parses the 1-based item number s and returns the corresponding
+// 0-based index.
+func parseItem(s string, count int) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > count {
+		return 0, fmt.Errorf("invalid item %q; want a number in [1, %d]", s, count)
+	}
+	return n - 1, nil
+}
+
+func checklistDone(items []ItemRecord) bool {
+	for _, item := range items {
+		if !item.acked() {
+			return false
+		}
+	}
+	return true
+}
+
+func printChecklist(out io.Writer, items []ItemRecord) {
+	for i, item := range items {
+		switch {
+		case !item.Done.IsZero():
+			fmt.Fprintf(out, "(check) %2d. [x] %s\n", i+1, item.Item)
+		case item.Waived != "":
+			fmt.Fprintf(out, "(check) %2d. [~] %s (waived: %s)\n", i+1, item.Item,
+				item.Waived)
+		default:
+			fmt.Fprintf(out, "(check) %2d. [ ] %s\n", i+1, item.Item)
+		}
+	}
+}
+
+// makeCheckCompleter returns a Completer for the checklist sub REPL.
+func makeCheckCompleter(count int) Completer {
+	return func(line string) []string {
+		commands := []string{"help", "?", "back", "done", "waive"}
+		completions := make([]string, 0, len(commands))
+		line = strings.ToLower(line)
+		for _, cmd := range commands {
+			if strings.HasPrefix(cmd, line) {
+				completions = append(completions, cmd)
+			}
+		}
+		if strings.HasPrefix(line, "done ") {
+			completions = completions[:0]
+			candidates := []string{"done all"}
+			for i := 1; i <= count; i++ {
+				candidates = append(candidates, "done "+strconv.Itoa(i))
+			}
+			for _, cand := range candidates {
+				if strings.HasPrefix(cand, line) {
+					completions = append(completions, cand)
+				}
+			}
+		}
+		return completions
+	}
+}
@@ -0,0 +1,191 @@
+package otium
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-quicktest/qt"
+
+	"github.com/marco-m/otium/expect"
+)
+
+func TestTickChecklistEmpty(t *testing.T) {
+	rec := &StepRecord{}
+
+	err := tick(rec, nil, nil, nil)
+
+	qt.Assert(t, qt.IsNil(err))
+}
+
+func TestTickChecklistInteractive(t *testing.T) {
+	stdin, stdout, exp := expect.New(100*time.Millisecond, expect.MatchMaxDef)
+	step := &Step{Title: "manual", Checklist: []string{"apples", "pears", "plums"}}
+	rec := newRunRecord("pcd", []*Step{step}).Steps[0]
+
+	term := NewLinePrompter(stdin, stdout)
+
+	asyncErr := make(chan error)
+	go func() {
+		asyncErr <- tick(&rec, time.Now, term, stdout)
+	}()
+
+	m, err := exp.ExpectMatch(expect.Literal("(check)>> "))
+	have := m.Upto()
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(have, `(check)  1. [ ] apples
+(check)  2. [ ] pears
+(check)  3. [ ] plums
+(check) Tick the items (done <n>, done all, waive <n> <reason>) or '?' for help
+(check)>> `))
+
+	err = exp.Send("done 2\n")
+	qt.Assert(t, qt.IsNil(err))
+	m, err = exp.ExpectMatch(expect.Literal("(check)>> "))
+	have = m.Upto()
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.StringContains(have, "(check)  2. [x] pears\n"))
+
+	err = exp.Send("waive 3 out of season\n")
+	qt.Assert(t, qt.IsNil(err))
+	m, err = exp.ExpectMatch(expect.Literal("(check)>> "))
+	have = m.Upto()
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.StringContains(have,
+		"(check)  3. [~] plums (waived: out of season)\n"))
+
+	err = exp.Send("done all\n")
+	qt.Assert(t, qt.IsNil(err))
+
+	err = <-asyncErr
+	qt.Assert(t, qt.IsNil(err))
+
+	qt.Assert(t, qt.IsFalse(rec.Checklist[0].Done.IsZero()))
+	qt.Assert(t, qt.IsFalse(rec.Checklist[1].Done.IsZero()))
+	qt.Assert(t, qt.IsTrue(rec.Checklist[2].Done.IsZero()))
+	qt.Assert(t, qt.Equals(rec.Checklist[2].Waived, "out of season"))
+}
+
+func TestTickChecklistBack(t *testing.T) {
+	stdin, stdout, exp := expect.New(100*time.Millisecond, expect.MatchMaxDef)
+	step := &Step{Title: "manual", Checklist: []string{"apples"}}
+	rec := newRunRecord("pcd", []*Step{step}).Steps[0]
+
+	term := NewLinePrompter(stdin, stdout)
+
+	asyncErr := make(chan error)
+	go func() {
+		asyncErr <- tick(&rec, time.Now, term, stdout)
+	}()
+
+	_, err := exp.ExpectMatch(expect.Literal("(check)>> "))
+	qt.Assert(t, qt.IsNil(err))
+	err = exp.Send("done 7\n")
+	qt.Assert(t, qt.IsNil(err))
+	have, err := exp.Expect(`(?s)done: .*\(check\)>> `)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.StringContains(have,
+		"done: invalid item \"7\"; want a number in [1, 1]\n"))
+
+	err = exp.Send("back\n")
+	qt.Assert(t, qt.IsNil(err))
+
+	err = <-asyncErr
+	qt.Assert(t, qt.ErrorIs(err, errBack))
+	qt.Assert(t, qt.IsTrue(rec.Checklist[0].Done.IsZero()))
+}
+
+func TestTickChecklistLastActionWins(t *testing.T) {
+	type testCase struct {
+		name       string
+		input      string
+		wantDone   bool
+		wantWaived string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		step := &Step{Title: "manual", Checklist: []string{"apples", "pears"}}
+		rec := newRunRecord("pcd", []*Step{step}).Steps[0]
+		term := NewLinePrompter(strings.NewReader(tc.input+"done 2\n"), io.Discard)
+
+		err := tick(&rec, time.Now, term, io.Discard)
+
+		qt.Assert(t, qt.IsNil(err))
+		qt.Assert(t, qt.Equals(!rec.Checklist[0].Done.IsZero(), tc.wantDone))
+		qt.Assert(t, qt.Equals(rec.Checklist[0].Waived, tc.wantWaived))
+	}
+
+	testCases := []testCase{
+		{
+			name:       "done then waive",
+			input:      "done 1\nwaive 1 out of season\n",
+			wantWaived: "out of season",
+		},
+		{
+			name:     "waive then done",
+			input:    "waive 1 out of season\ndone 1\n",
+			wantDone: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
+
+func TestTickChecklistUsesTheClock(t *testing.T) {
+	clock := time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC)
+	now := func() time.Time { return clock }
+	step := &Step{Title: "manual", Checklist: []string{"apples", "pears"}}
+	rec := newRunRecord("pcd", []*Step{step}).Steps[0]
+	term := NewLinePrompter(strings.NewReader("done 1\ndone all\n"), io.Discard)
+
+	err := tick(&rec, now, term, io.Discard)
+
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(rec.Checklist[0].Done, clock))
+	qt.Assert(t, qt.Equals(rec.Checklist[1].Done, clock))
+}
+
+func TestCheckCompleter(t *testing.T) {
+	type testCase struct {
+		name string
+		line string
+		want []string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		sut := makeCheckCompleter(2)
+
+		have := sut(tc.line)
+
+		qt.Assert(t, qt.DeepEquals(have, tc.want))
+	}
+
+	testCases := []testCase{
+		{
+			name: "empty line expands to all commands",
+			want: []string{"help", "?", "back", "done", "waive"},
+		},
+		{
+			name: "d expands to done",
+			line: "d",
+			want: []string{"done"},
+		},
+		{
+			name: "done expands to items",
+			line: "done ",
+			want: []string{"done all", "done 1", "done 2"},
+		},
+		{
+			name: "done a expands to all",
+			line: "done a",
+			want: []string{"done all"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
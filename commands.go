@@ -1,8 +1,11 @@
 package otium
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"golang.org/x/exp/maps"
 	"golang.org/x/exp/slices"
@@ -11,21 +14,241 @@ import (
 // cmdVariables implements the "variables" command.
 func cmdVariables(pcd *Procedure) {
 	if len(pcd.bag.bag) == 0 {
-		fmt.Println("no variables")
+		fmt.Fprintln(pcd.out, "no variables")
 		return
 	}
 
+	if pcd.profile.name != "" {
+		fmt.Fprintf(pcd.out, "profile: %s (%s)\n", pcd.profile.name, pcd.profile.path)
+	}
+
 	keys := maps.Keys(pcd.bag.bag)
 	slices.Sort(keys)
 
 	for _, k := range keys {
 		v := pcd.bag.bag[k]
 		if v.set {
-			fmt.Printf("%s (%s): %v\n", k, v.Desc, v.val)
+			var from string
+			if pcd.fromProfile(k) {
+				from = " (profile)"
+			}
+			fmt.Fprintf(pcd.out, "%s (%s): %v%s\n", k, v.Desc, v.val, from)
 		} else {
-			fmt.Printf("%s (%s): <unset>\n", k, v.Desc)
+			fmt.Fprintf(pcd.out, "%s (%s): <unset>\n", k, v.Desc)
+		}
+	}
+}
+
+// cmdSet implements the "set" command.
+func cmdSet(pcd *Procedure, key, val string) error {
+	variable, ok := pcd.bag.bag[key]
+	if !ok {
+		return fmt.Errorf("set: unknown variable %q", key)
+	}
+	if variable.Fn != nil {
+		if err := variable.Fn(val); err != nil {
+			return fmt.Errorf("set: %s", err)
+		}
+	}
+	warnConsumed(pcd, key)
+	pcd.bag.Put(key, val)
+	return nil
+}
+
+// cmdUnset implements the "unset" command.
+func cmdUnset(pcd *Procedure, key string) error {
+	variable, ok := pcd.bag.bag[key]
+	if !ok {
+		return fmt.Errorf("unset: unknown variable %q", key)
+	}
+	warnConsumed(pcd, key)
+	variable.val, variable.set = "", false
+	pcd.bag.bag[key] = variable
+	return nil
+}
+
+// cmdEdit implements the "edit" command: it prompts for the value of key,
+// pre-filled with the current value (unless the variable is secret).
+func cmdEdit(pcd *Procedure, key string) error {
+	variable, ok := pcd.bag.bag[key]
+	if !ok {
+		return fmt.Errorf("edit: unknown variable %q", key)
+	}
+	suggestion := variable.val
+	if variable.Secret {
+		suggestion = ""
+	}
+	pcd.term.SetCompleter(nil)
+	for {
+		fmt.Fprintf(pcd.out, "(edit) Enter %s (empty line to cancel)\n", variable.Desc)
+		line, err := pcd.term.PromptWithSuggestion("(edit)>> ", suggestion, -1)
+		if err != nil {
+			return err
+		}
+		val := strings.TrimSpace(line)
+		if val == "" {
+			return nil
+		}
+		if variable.Fn != nil {
+			if err := variable.Fn(val); err != nil {
+				fmt.Fprintln(pcd.out, err)
+				continue
+			}
+		}
+		warnConsumed(pcd, key)
+		pcd.bag.Put(key, val)
+		return nil
+	}
+}
+
+// warnConsumed warns the user if key has already been consumed by some
+// completed steps, since changing it now will not affect them.
+func warnConsumed(pcd *Procedure, key string) {
+	var users []string
+	for i := 0; i < pcd.stepIdx; i++ {
+		status := pcd.run.Steps[i].Status
+		if status != StatusDone && status != StatusRetried {
+			continue
 		}
+		if stepUses(pcd.steps[i], key) {
+			users = append(users, strconv.Itoa(i+1))
+		}
+	}
+	if len(users) > 0 {
+		fmt.Fprintf(pcd.out, "warning: variable %q has already been used by completed steps: %s\n",
+			key, strings.Join(users, ", "))
+	}
+}
+
+// stepUses returns true if step either declares key in its Vars or references
+// it in its Desc.
+func stepUses(step *Step, key string) bool {
+	for _, variable := range step.Vars {
+		if variable.Name == key {
+			return true
+		}
+	}
+	fields, err := templateFields(step.Desc)
+	if err != nil {
+		return false
 	}
+	return slices.Contains(fields, key)
+}
+
+// cmdShow implements the "show" command: it previews step n (1-based)
+// without running it. If n is 0, it previews the next step.
+func cmdShow(pcd *Procedure, n int) error {
+	if n == 0 {
+		n = pcd.stepIdx + 1
+	}
+	if n < 1 || n > len(pcd.steps) {
+		return fmt.Errorf("show: invalid step %d; want a number in [1, %d]",
+			n, len(pcd.steps))
+	}
+	step := pcd.steps[n-1]
+
+	fmt.Fprintf(pcd.out, "\n## %d. %s %s (preview)\n\n", n, pcd.icon(n-1), step.Title)
+	if step.Desc != "" {
+		if err := renderPreview(pcd.out, step.Desc, pcd.bag.bag); err != nil {
+			return fmt.Errorf("show: %s", err)
+		}
+		fmt.Fprintf(pcd.out, "\n\n")
+	}
+	if len(step.Checklist) > 0 {
+		for _, item := range step.Checklist {
+			fmt.Fprintf(pcd.out, "- [ ] %s\n", item)
+		}
+		fmt.Fprintln(pcd.out)
+	}
+	if len(step.Vars) > 0 {
+		fmt.Fprintf(pcd.out, "Variables asked by this step:\n")
+		for _, variable := range step.Vars {
+			val := "<unset>"
+			if v := pcd.bag.bag[variable.Name]; v.set {
+				val = v.val
+				if v.Secret {
+					val = redacted
+				}
+			}
+			fmt.Fprintf(pcd.out, "- %s (%s): %s\n", variable.Name, variable.Desc, val)
+		}
+		fmt.Fprintln(pcd.out)
+	}
+	return nil
+}
+
+// cmdQuit implements the "quit" command. If the procedure is in progress, it
+// asks for confirmation. It returns ErrQuit if the REPL must quit, nil
+// otherwise.
+func cmdQuit(pcd *Procedure) error {
+	if !pcd.started() {
+		return ErrQuit
+	}
+	fmt.Fprintf(pcd.out, "(top) The procedure is in progress (next step: %d of %d)\n",
+		pcd.stepIdx+1, len(pcd.steps))
+	line, err := pcd.term.Prompt("(top) Quit anyway? [y/N] ")
+	if err != nil {
+		// The user cannot answer (for example, EOF): quit.
+		return ErrQuit
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return ErrQuit
+	default:
+		return nil
+	}
+}
+
+// cmdSkip implements the "skip" command. If steps is empty, it skips the
+// next step.
+func cmdSkip(pcd *Procedure, steps []int) error {
+	if len(steps) == 0 {
+		steps = []int{pcd.stepIdx + 1}
+	}
+	for _, n := range steps {
+		if n < 1 || n > len(pcd.steps) {
+			return fmt.Errorf("skip: invalid step %d; want a number in [1, %d]",
+				n, len(pcd.steps))
+		}
+		if n-1 < pcd.stepIdx {
+			return fmt.Errorf("skip: step %d is already behind", n)
+		}
+	}
+	for _, n := range steps {
+		pcd.run.Steps[n-1].Status = StatusSkipped
+	}
+	return nil
+}
+
+// cmdStats implements the "stats" command. If dir is empty, it uses the
+// directory where the run records of pcd are saved.
+func cmdStats(pcd *Procedure, dir string) error {
+	if dir == "" {
+		var err error
+		if dir, err = pcd.runLogDir(); err != nil {
+			return fmt.Errorf("stats: %s", err)
+		}
+	}
+	runs, err := loadRunRecords(dir)
+	if err != nil {
+		// Some records might be corrupted; show the stats of the others.
+		fmt.Fprintf(pcd.out, "stats: warning: %s\n", err)
+	}
+	if len(runs) == 0 {
+		fmt.Fprintf(pcd.out, "no run records in %s\n", dir)
+		return nil
+	}
+	writeStats(pcd.out, len(runs), computeStats(runs, pcd.steps))
+	return nil
+}
+
+// cmdPreflight implements the "preflight" command.
+func cmdPreflight(pcd *Procedure) error {
+	if len(pcd.Checks) == 0 {
+		fmt.Fprintln(pcd.out, "no pre-flight checks")
+		return nil
+	}
+	return runChecks(context.Background(), pcd.Checks, pcd.out)
 }
 
 type visitFn func(pcd *Procedure, step *Step) error
@@ -41,13 +264,30 @@ func visitStep(pcd *Procedure, visitor visitFn) error {
 	}
 	step := pcd.steps[pcd.stepIdx]
 
-	fmt.Printf("\n## %d. %s %s\n\n", pcd.stepIdx+1, step.Icon(), step.Title)
+	fmt.Fprintf(pcd.out, "\n## %d. %s %s", pcd.stepIdx+1, pcd.icon(pcd.stepIdx), step.Title)
+	if pcd.rehearsed(pcd.stepIdx) {
+		fmt.Fprintf(pcd.out, " (REHEARSAL)")
+	}
+	if visitor != nil {
+		elapsed := pcd.now().Sub(pcd.run.Start).Round(time.Second)
+		fmt.Fprintf(pcd.out, " (elapsed %s)", elapsed)
+		if pcd.dryRun {
+			fmt.Fprintf(pcd.out, " (dry run)")
+		}
+	}
+	fmt.Fprintf(pcd.out, "\n\n")
 
 	if step.Desc != "" {
-		if err := renderTemplate(os.Stdout, step.Desc, pcd.bag.bag); err != nil {
+		if err := renderTemplate(pcd.out, step.Desc, pcd.bag.bag); err != nil {
 			return fmt.Errorf("%s %w", err, ErrUnrecoverable)
 		}
-		fmt.Printf("\n\n")
+		fmt.Fprintf(pcd.out, "\n\n")
+	}
+	if len(step.Checklist) > 0 {
+		for _, item := range step.Checklist {
+			fmt.Fprintf(pcd.out, "- [ ] %s\n", item)
+		}
+		fmt.Fprintln(pcd.out)
 	}
 
 	if visitor != nil {
@@ -61,19 +301,62 @@ func visitStep(pcd *Procedure, visitor visitFn) error {
 }
 
 func visitAsNext(pcd *Procedure, step *Step) error {
+	rec := &pcd.run.Steps[pcd.stepIdx]
+	if rec.Start.IsZero() {
+		rec.Start = pcd.now()
+	}
+
 	// Prompt the user for the declared variables.
 	for _, variable := range step.Vars {
-		if _, err := pcd.bag.ask(variable.Name, pcd.term); err != nil {
+		if _, err := pcd.bag.ask(variable.Name, pcd.term, pcd.out); err != nil {
 			return err
 		}
 	}
 
+	if pcd.dryRun {
+		return dryRun(pcd.stepIdx+1, step, rec, pcd.now, pcd.bag, pcd.uctx, pcd.out)
+	}
+
+	// Ask the user to acknowledge the checklist.
+	if err := tick(rec, pcd.now, pcd.term, pcd.out); err != nil {
+		return err
+	}
+
 	// Run the step.
 	if step.Run != nil {
-		if err := step.Run(pcd.bag, pcd.uctx); err != nil {
+		run := step.Run
+		if pcd.rehearsed(pcd.stepIdx) {
+			if step.FakeRun == nil {
+				// We cannot know when, nor if, the human has performed it.
+				fmt.Fprintf(pcd.out, "(rehearsal) Not run: perform the step manually, as described above (recorded as skipped)\n")
+				rec.Status = StatusSkipped
+				return nil
+			}
+			run = step.FakeRun
+		}
+		rec.Attempts++
+		if err := run(pcd.bag, pcd.uctx); err != nil {
+			rec.Status = StatusFailed
 			return fmt.Errorf("step %d: %w", pcd.stepIdx+1, err)
 		}
 	}
 
+	// Verify the manual step.
+	if err := verify(context.Background(), step, rec, pcd.bag, pcd.uctx,
+		pcd.term, pcd.out); err != nil {
+		return err
+	}
+
+	rec.Status = StatusDone
+	if rec.Attempts > 1 {
+		rec.Status = StatusRetried
+	}
+	if step.Run == nil && len(step.Checklist) == 0 && step.Verify == nil {
+		// The human performs the step now: see closeStep.
+		pcd.pending = rec
+		return nil
+	}
+	rec.End = pcd.now()
+
 	return nil
 }
@@ -1,46 +1,203 @@
 package otium
 
 import (
+	"bytes"
+	"errors"
 	"io"
-	"os"
 	"testing"
+	"time"
 
 	"github.com/go-quicktest/qt"
 )
 
-func setupTestCmdVariables(t *testing.T) (*os.File, func()) {
-	stdoutRd, stdoutWr, err := os.Pipe()
-	qt.Assert(t, qt.IsNil(err))
-	oldStdout := os.Stdout
-	os.Stdout = stdoutWr
-	cleanup := func() { os.Stdout = oldStdout }
-	return stdoutRd, cleanup
-}
-
 func TestCmdVariablesEmpty(t *testing.T) {
-	pcd := NewProcedure(ProcedureOpts{})
-	stdoutRd, cleanup := setupTestCmdVariables(t)
-	defer cleanup()
+	var out bytes.Buffer
+	pcd := NewProcedure(ProcedureOpts{Stdout: &out})
 
 	cmdVariables(pcd)
 
-	os.Stdout.Close()
-	buf, err := io.ReadAll(stdoutRd)
-	qt.Assert(t, qt.IsNil(err))
-	qt.Assert(t, qt.Equals(string(buf), "no variables\n"))
+	qt.Assert(t, qt.Equals(out.String(), "no variables\n"))
 }
 
 func TestCmdVariablesNotEmpty(t *testing.T) {
-	pcd := NewProcedure(ProcedureOpts{})
+	var out bytes.Buffer
+	pcd := NewProcedure(ProcedureOpts{Stdout: &out})
 	pcd.Put("fruit", "mango")
 	pcd.Put("amount", "100")
-	stdoutRd, cleanup := setupTestCmdVariables(t)
-	defer cleanup()
 
 	cmdVariables(pcd)
 
-	os.Stdout.Close()
-	buf, err := io.ReadAll(stdoutRd)
+	qt.Assert(t, qt.Equals(out.String(), "amount (): 100\nfruit (): mango\n"))
+}
+
+func TestCmdSkip(t *testing.T) {
+	var out bytes.Buffer
+	pcd := NewProcedure(ProcedureOpts{Stdout: &out})
+	pcd.AddStep(&Step{Title: "one"})
+	pcd.AddStep(&Step{Title: "two"})
+	pcd.AddStep(&Step{Title: "three"})
+	pcd.run = newRunRecord("pcd", pcd.steps)
+	pcd.stepIdx = 1
+
+	err := cmdSkip(pcd, []int{1})
+	qt.Assert(t, qt.ErrorMatches(err, `skip: step 1 is already behind`))
+
+	err = cmdSkip(pcd, []int{4})
+	qt.Assert(t, qt.ErrorMatches(err, `skip: invalid step 4; want a number in \[1, 3\]`))
+
+	err = cmdSkip(pcd, nil)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(pcd.run.Steps[1].Status, StatusSkipped))
+	qt.Assert(t, qt.Equals(pcd.run.Steps[2].Status, StatusPending))
+}
+
+func TestCmdSetUnset(t *testing.T) {
+	var out bytes.Buffer
+	pcd := NewProcedure(ProcedureOpts{Stdout: &out})
+	pcd.AddStep(&Step{
+		Title: "one",
+		Vars:  []Variable{{Name: "fruit"}},
+	})
+	pcd.AddStep(&Step{Title: "two", Desc: "Eat {{.amount}} fruits"})
+	pcd.AddStep(&Step{Title: "three"})
+	pcd.bag.bag["fruit"] = Variable{
+		Name: "fruit",
+		Fn: func(val string) error {
+			if val == "potato" {
+				return errors.New("not a fruit")
+			}
+			return nil
+		},
+	}
+	pcd.bag.bag["amount"] = Variable{Name: "amount"}
+	pcd.run = newRunRecord("pcd", pcd.steps)
+	pcd.run.Steps[0].Status = StatusDone
+	pcd.run.Steps[1].Status = StatusSkipped
+	pcd.stepIdx = 2
+
+	err := cmdSet(pcd, "vegetable", "potato")
+	qt.Assert(t, qt.ErrorMatches(err, `set: unknown variable "vegetable"`))
+
+	err = cmdSet(pcd, "fruit", "potato")
+	qt.Assert(t, qt.ErrorMatches(err, `set: not a fruit`))
+
+	err = cmdSet(pcd, "fruit", "mango")
+	qt.Assert(t, qt.IsNil(err))
+	val, err := pcd.bag.Get("fruit")
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(val, "mango"))
+
+	// Step 2 uses amount, but has been skipped: no warning.
+	err = cmdSet(pcd, "amount", "3")
+	qt.Assert(t, qt.IsNil(err))
+
+	err = cmdUnset(pcd, "fruit")
+	qt.Assert(t, qt.IsNil(err))
+	_, err = pcd.bag.Get("fruit")
+	qt.Assert(t, qt.ErrorMatches(err, `key not found: "fruit"`))
+
+	qt.Assert(t, qt.Equals(out.String(),
+		`warning: variable "fruit" has already been used by completed steps: 1
+warning: variable "fruit" has already been used by completed steps: 1
+`))
+}
+
+func TestStepUses(t *testing.T) {
+	step := &Step{
+		Title: "one",
+		Desc:  "{{if .b}}{{.c}}{{end}}",
+		Vars:  []Variable{{Name: "a"}},
+	}
+
+	qt.Assert(t, qt.IsTrue(stepUses(step, "a")))
+	qt.Assert(t, qt.IsTrue(stepUses(step, "b")))
+	qt.Assert(t, qt.IsTrue(stepUses(step, "c")))
+	qt.Assert(t, qt.IsFalse(stepUses(step, "d")))
+}
+
+func TestCmdShow(t *testing.T) {
+	var out bytes.Buffer
+	pcd := NewProcedure(ProcedureOpts{Stdout: &out})
+	pcd.AddStep(&Step{Title: "one"})
+	pcd.AddStep(&Step{
+		Title:     "two",
+		Desc:      "Buy {{.amount}} {{.fruit}}",
+		Checklist: []string{"paid"},
+		Vars: []Variable{
+			{Name: "fruit", Desc: "Fruit"},
+			{Name: "card", Desc: "Credit card"},
+		},
+	})
+	pcd.bag.bag["fruit"] = Variable{Name: "fruit", Desc: "Fruit"}
+	pcd.bag.bag["card"] = Variable{Name: "card", Desc: "Credit card", Secret: true}
+	pcd.Put("amount", "3")
+	pcd.Put("card", "1234")
+
+	err := cmdShow(pcd, 3)
+	qt.Assert(t, qt.ErrorMatches(err, `show: invalid step 3; want a number in \[1, 2\]`))
+
+	err = cmdShow(pcd, 2)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(out.String(), `
+## 2. 🤠 two (preview)
+
+Buy 3 <unset:fruit>
+
+- [ ] paid
+
+Variables asked by this step:
+- fruit (Fruit): <unset>
+- card (Credit card): <redacted>
+
+`))
+}
+
+// clockPrompter is a Prompter that answers each prompt with the next line,
+// advancing the fake clock of the procedure by the time the human takes.
+type clockPrompter struct {
+	linePrompter
+	clock *time.Time
+	lines []string
+	waits []time.Duration
+}
+
+func (cp *clockPrompter) Prompt(prompt string) (string, error) {
+	if len(cp.lines) == 0 {
+		return "", io.EOF
+	}
+	line := cp.lines[0]
+	*cp.clock = cp.clock.Add(cp.waits[0])
+	cp.lines, cp.waits = cp.lines[1:], cp.waits[1:]
+	return line, nil
+}
+
+func TestVisitManualStepEndsAtNextCommand(t *testing.T) {
+	clock := time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC)
+	var out bytes.Buffer
+	pcd := NewProcedure(ProcedureOpts{
+		Title:    "Manual steps",
+		Stdout:   &out,
+		Stderr:   io.Discard,
+		StateDir: t.TempDir(),
+		Prompter: &clockPrompter{
+			clock: &clock,
+			lines: []string{"next", "next"},
+			// The human reads step 1, then spends 10 minutes performing it.
+			waits: []time.Duration{time.Minute, 10 * time.Minute},
+		},
+	})
+	pcd.now = func() time.Time { return clock }
+	pcd.AddStep(&Step{Title: "one"})
+	pcd.AddStep(&Step{Title: "two"})
+
+	err := pcd.Execute([]string{"test"})
+
 	qt.Assert(t, qt.IsNil(err))
-	qt.Assert(t, qt.Equals(string(buf), "amount (): 100\nfruit (): mango\n"))
+	qt.Assert(t, qt.Equals(pcd.run.Steps[0].Status, StatusDone))
+	qt.Assert(t, qt.Equals(pcd.run.Steps[0].Duration(), 10*time.Minute))
+	// The last step ends with the procedure.
+	qt.Assert(t, qt.Equals(pcd.run.Steps[1].Status, StatusDone))
+	qt.Assert(t, qt.Equals(pcd.run.Steps[1].End, clock))
+	qt.Assert(t, qt.StringContains(out.String(), "## 1. 🤠 one (elapsed 1m0s)\n"))
+	qt.Assert(t, qt.StringContains(out.String(), "## 2. 🤠 two (elapsed 11m0s)\n"))
 }
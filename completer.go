@@ -0,0 +1,134 @@
+package otium
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/alecthomas/kong"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// Kinds of completion of a positional argument of the top level REPL,
+// selected with the struct tag `complete:"<kind>"` (see topcli).
+const (
+	completeCommands = "commands" // The names of the commands.
+	completeSteps    = "steps"    // The step numbers.
+	completeVars     = "vars"     // The variable names.
+	completeValues   = "values"   // The values of the variable in the previous argument.
+)
+
+// makeTopCompleter returns a Completer for the top level REPL. The
+// completer is driven by the kong model of the parser: it completes the
+// command names and then, for each positional argument, either the
+// candidates selected by its "complete" tag or its enum values.
+func makeTopCompleter(pcd *Procedure) Completer {
+	return func(line string) []string {
+		words := strings.Fields(line)
+		// The word to complete is the last one, unless the line ends with a
+		// space, in which case it is a new, empty, word.
+		var prefix string
+		r, _ := utf8.DecodeLastRuneInString(line)
+		if len(words) > 0 && !unicode.IsSpace(r) {
+			prefix = words[len(words)-1]
+			words = words[:len(words)-1]
+		}
+		head := line[:len(line)-len(prefix)]
+
+		if len(words) == 0 {
+			return filterPrefix(commandNames(pcd.parser), "", prefix, "")
+		}
+
+		node := findCommand(pcd.parser, words[0])
+		if node == nil {
+			return []string{}
+		}
+		args := words[1:]
+		var value *kong.Value
+		switch {
+		case len(args) < len(node.Positional):
+			value = node.Positional[len(args)]
+		case len(node.Positional) > 0 &&
+			node.Positional[len(node.Positional)-1].IsSlice():
+			value = node.Positional[len(node.Positional)-1]
+		default:
+			return []string{}
+		}
+		return filterPrefix(candidates(pcd, value, args, prefix), head, prefix, " ")
+	}
+}
+
+// candidates returns the completion candidates of the positional argument
+// value, given the previous arguments args and the prefix being completed.
+func candidates(pcd *Procedure, value *kong.Value, args []string, prefix string) []string {
+	switch value.Tag.Get("complete") {
+	case completeCommands:
+		return commandNames(pcd.parser)
+	case completeSteps:
+		steps := make([]string, 0, len(pcd.steps))
+		for i := range pcd.steps {
+			steps = append(steps, strconv.Itoa(i+1))
+		}
+		return steps
+	case completeVars:
+		keys := maps.Keys(pcd.bag.bag)
+		slices.Sort(keys)
+		return keys
+	case completeValues:
+		if len(args) == 0 {
+			return nil
+		}
+		return pcd.bag.bag[args[len(args)-1]].completions(prefix)
+	}
+	if value.Enum != "" {
+		enum := maps.Keys(value.EnumMap())
+		slices.Sort(enum)
+		return enum
+	}
+	return nil
+}
+
+// completions returns the value completions of variable: the output of
+// Complete if set, Values otherwise.
+func (variable Variable) completions(prefix string) []string {
+	if variable.Complete != nil {
+		return variable.Complete(prefix)
+	}
+	return variable.Values
+}
+
+// commandNames returns the names of the visible top level commands.
+func commandNames(parser *kong.Kong) []string {
+	var names []string
+	for _, node := range parser.Model.Children {
+		if !node.Hidden {
+			names = append(names, node.Name)
+		}
+	}
+	return names
+}
+
+// findCommand returns the top level command called name, or nil.
+func findCommand(parser *kong.Kong, name string) *kong.Node {
+	for _, node := range parser.Model.Children {
+		if node.Name == name {
+			return node
+		}
+	}
+	return nil
+}
+
+// filterPrefix returns head+candidate+tail for each candidate that matches
+// prefix (case-insensitive).
+func filterPrefix(candidates []string, head, prefix, tail string) []string {
+	completions := make([]string, 0, len(candidates))
+	prefix = strings.ToLower(prefix)
+	for _, cand := range candidates {
+		if strings.HasPrefix(strings.ToLower(cand), prefix) {
+			completions = append(completions, head+cand+tail)
+		}
+	}
+	return completions
+}
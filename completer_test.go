@@ -0,0 +1,124 @@
+package otium
+
+import (
+	"io"
+	"testing"
+
+	"github.com/go-quicktest/qt"
+)
+
+func TestTopCompleter(t *testing.T) {
+	type testCase struct {
+		name string
+		line string
+		want []string
+	}
+
+	parser, err := newParser([]Command{{
+		Name: "whoami",
+		Run:  func(out io.Writer, args []string, bag Bag, uctx any) error { return nil },
+	}}, io.Discard, io.Discard)
+	qt.Assert(t, qt.IsNil(err))
+	pcd := NewProcedure(ProcedureOpts{})
+	pcd.parser = parser
+	pcd.AddStep(&Step{Title: "one"})
+	pcd.AddStep(&Step{Title: "two"})
+	pcd.bag.bag["fruit"] = Variable{Name: "fruit", Values: []string{"banana", "mango"}}
+	pcd.bag.bag["amount"] = Variable{
+		Name: "amount",
+		Complete: func(prefix string) []string {
+			return []string{prefix + "0", prefix + "5"}
+		},
+	}
+	pcd.bag.bag["URL"] = Variable{Name: "URL"}
+
+	run := func(t *testing.T, tc testCase) {
+		sut := makeTopCompleter(pcd)
+
+		have := sut(tc.line)
+
+		qt.Assert(t, qt.DeepEquals(have, tc.want))
+	}
+
+	testCases := []testCase{
+		{
+			name: "s expands to commands",
+			line: "s",
+			want: []string{"show", "skip", "stats", "set"},
+		},
+		{
+			name: "custom commands are completed",
+			line: "w",
+			want: []string{"whoami"},
+		},
+		{
+			name: "hidden commands are not completed",
+			line: "?",
+			want: []string{},
+		},
+		{
+			name: "unknown command expands to nothing",
+			line: "foo ",
+			want: []string{},
+		},
+		{
+			name: "help expands to commands",
+			line: "help ne",
+			want: []string{"help next "},
+		},
+		{
+			name: "show expands to step numbers",
+			line: "show ",
+			want: []string{"show 1 ", "show 2 "},
+		},
+		{
+			name: "skip expands to step numbers also after the first one",
+			line: "skip 1 ",
+			want: []string{"skip 1 1 ", "skip 1 2 "},
+		},
+		{
+			name: "show takes only one argument",
+			line: "show 1 ",
+			want: []string{},
+		},
+		{
+			name: "set expands to all variables",
+			line: "set ",
+			want: []string{"set URL ", "set amount ", "set fruit "},
+		},
+		{
+			name: "unset f expands to fruit",
+			line: "unset f",
+			want: []string{"unset fruit "},
+		},
+		{
+			name: "edit is case insensitive",
+			line: "edit u",
+			want: []string{"edit URL "},
+		},
+		{
+			name: "set key expands to values",
+			line: "set fruit ",
+			want: []string{"set fruit banana ", "set fruit mango "},
+		},
+		{
+			name: "set key expands to matching values",
+			line: "set fruit b",
+			want: []string{"set fruit banana "},
+		},
+		{
+			name: "set key expands with completer function",
+			line: "set amount 1",
+			want: []string{"set amount 10 ", "set amount 15 "},
+		},
+		{
+			name: "set key without values expands to nothing",
+			line: "set URL ",
+			want: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
@@ -0,0 +1,41 @@
+package otium
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const dryRunBanner = "**DRY RUN**: the automated steps are not run, the manual steps are only shown."
+
+// dryRun shows what step n would do, without doing it, and records it as
+// done at the time returned by now. For an automated step, it shows the output of Step.DryRun, if set,
+// and never calls Step.Run. For a manual step, it neither asks to tick the
+// checklist nor calls Step.Verify.
+func dryRun(n int, step *Step, rec *StepRecord, now func() time.Time, bag Bag, uctx any,
+	out io.Writer,
+) error {
+	switch {
+	case step.DryRun != nil:
+		what, err := step.DryRun(bag, uctx)
+		if err != nil {
+			rec.Status = StatusFailed
+			return fmt.Errorf("step %d: dry run: %w", n, err)
+		}
+		fmt.Fprintf(out, "(dry-run) Would run:\n")
+		for _, line := range strings.Split(strings.TrimSpace(what), "\n") {
+			fmt.Fprintf(out, "    %s\n", line)
+		}
+	case step.Run != nil:
+		fmt.Fprintf(out, "(dry-run) Would run the automation (no DryRun to describe it)\n")
+	case step.Verify != nil:
+		fmt.Fprintf(out, "(dry-run) Would ask you to perform the step, then verify it\n")
+	default:
+		fmt.Fprintf(out, "(dry-run) Would ask you to perform the step\n")
+	}
+
+	rec.End = now()
+	rec.Status = StatusDone
+	return nil
+}
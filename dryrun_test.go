@@ -0,0 +1,87 @@
+package otium
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-quicktest/qt"
+)
+
+func TestDryRun(t *testing.T) {
+	type testCase struct {
+		name       string
+		step       *Step
+		wantOut    string
+		wantErr    string
+		wantStatus StepStatus
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		var out strings.Builder
+		clock := time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC)
+		rec := &StepRecord{Start: clock.Add(-time.Minute)}
+
+		err := dryRun(2, tc.step, rec, func() time.Time { return clock }, NewBag(), nil, &out)
+
+		if tc.wantErr != "" {
+			qt.Assert(t, qt.ErrorMatches(err, tc.wantErr))
+		} else {
+			qt.Assert(t, qt.IsNil(err))
+		}
+		qt.Assert(t, qt.Equals(out.String(), tc.wantOut))
+		qt.Assert(t, qt.Equals(rec.Status, tc.wantStatus))
+		if tc.wantStatus == StatusDone {
+			qt.Assert(t, qt.Equals(rec.Duration(), time.Minute))
+		}
+	}
+
+	runFn := func(bag Bag, uctx any) error {
+		panic("Run must not be called")
+	}
+
+	testCases := []testCase{
+		{
+			name:       "manual step",
+			step:       &Step{},
+			wantOut:    "(dry-run) Would ask you to perform the step\n",
+			wantStatus: StatusDone,
+		},
+		{
+			name:       "automated step without DryRun",
+			step:       &Step{Run: runFn},
+			wantOut:    "(dry-run) Would run the automation (no DryRun to describe it)\n",
+			wantStatus: StatusDone,
+		},
+		{
+			name: "automated step with DryRun",
+			step: &Step{
+				Run: runFn,
+				DryRun: func(bag Bag, uctx any) (string, error) {
+					return "kubectl drain node-1\nkubectl delete node node-1\n", nil
+				},
+			},
+			wantOut: `(dry-run) Would run:
+    kubectl drain node-1
+    kubectl delete node node-1
+`,
+			wantStatus: StatusDone,
+		},
+		{
+			name: "DryRun fails",
+			step: &Step{
+				Run: runFn,
+				DryRun: func(bag Bag, uctx any) (string, error) {
+					return "", errors.New("no node")
+				},
+			},
+			wantErr:    "step 2: dry run: no node",
+			wantStatus: StatusFailed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"time"
 
 	"github.com/marco-m/otium"
 )
@@ -14,7 +15,7 @@ import (
 func main() {
 	if err := run(); err != nil {
 		fmt.Println("error:", err)
-		os.Exit(1)
+		os.Exit(otium.ExitCode(err))
 	}
 }
 
@@ -59,6 +60,11 @@ Download the previous URL and put it in the pwd directory.
 
     curl --location -O {{.URL}}
 `,
+		ExpectedDuration: 2 * time.Minute,
+		Checklist: []string{
+			"The file has been downloaded",
+			"The file is in the pwd directory",
+		},
 		//Run: func(bag otium.Bag) error {
 		//	// actually download the URL and save it to bag[pwd]/bag[file]
 		//},
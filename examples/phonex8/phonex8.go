@@ -11,7 +11,7 @@ import (
 func main() {
 	if err := run(); err != nil {
 		fmt.Println("error:", err)
-		os.Exit(1)
+		os.Exit(otium.ExitCode(err))
 	}
 }
 
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/marco-m/otium"
@@ -11,7 +12,7 @@ import (
 func main() {
 	if err := run(); err != nil {
 		fmt.Println("error:", err)
-		os.Exit(1)
+		os.Exit(otium.ExitCode(err))
 	}
 }
 
@@ -32,6 +33,15 @@ In this example the user context is initialized:
 			}
 			return foo.NewClient(), nil
 		},
+		// Procedure-specific commands also receive the user context.
+		Commands: []otium.Command{{
+			Name: "status",
+			Help: "Show the status of the client.",
+			Run: func(out io.Writer, args []string, bag otium.Bag, uctx any) error {
+				fmt.Fprintln(out, uctx.(*foo.Client))
+				return nil
+			},
+		}},
 	})
 
 	pcd.AddStep(&otium.Step{
@@ -0,0 +1,152 @@
+package expect
+
+import (
+	"errors"
+	"io"
+	"regexp"
+)
+
+type caseKind int
+
+const (
+	casePattern caseKind = iota
+	caseEOF
+	caseTimeout
+)
+
+// Case is one of the alternatives of [Expect.ExpectAny]. Create it with
+// [Re], [On], [EOF] or [Timeout].
+type Case struct {
+	kind    caseKind
+	re      string
+	matcher Matcher
+	handler func(m Match) error
+}
+
+// On returns the Case matching matcher. If handler is not nil, ExpectAny
+// calls it with the match and returns its error.
+func On(matcher Matcher, handler func(m Match) error) Case {
+	return Case{kind: casePattern, matcher: matcher, handler: handler}
+}
+
+// Re returns the Case matching the regular expression re. If handler is not
+// nil, ExpectAny calls it with the match and returns its error.
+func Re(re string, handler func(m Match) error) Case {
+	return Case{kind: casePattern, re: re, handler: handler}
+}
+
+// EOF returns the Case matching the end of the input. The Match contains the
+// input read so far. If handler is not nil, ExpectAny calls it with the match
+// and returns its error.
+func EOF(handler func(m Match) error) Case {
+	return Case{kind: caseEOF, handler: handler}
+}
+
+// Timeout returns the Case matching the expiration of the timeout. The Match
+// contains the input read so far, that is kept for the next call. If handler
+// is not nil, ExpectAny calls it with the match and returns its error.
+func Timeout(handler func(m Match) error) Case {
+	return Case{kind: caseTimeout, handler: handler}
+}
+
+// Match is the result of [Expect.ExpectAny].
+type Match struct {
+	// Case is the index of the Case that matched, or -1 if none.
+	Case int
+	// Before is the input read before the match, discarded.
+	Before string
+	// Groups are the submatches: Groups[0] is the whole match, Groups[i] is
+	// the i-th parenthesized subexpression (empty if it did not
+	// participate in the match).
+	Groups []string
+	names  []string
+}
+
+// Text returns the whole match.
+func (m Match) Text() string {
+	if len(m.Groups) == 0 {
+		return ""
+	}
+	return m.Groups[0]
+}
+
+// Group returns the i-th submatch, or the empty string if there is no such
+// submatch.
+func (m Match) Group(i int) string {
+	if i < 0 || i >= len(m.Groups) {
+		return ""
+	}
+	return m.Groups[i]
+}
+
+// Upto returns the input up to and including the match, that is, Before
+// followed by the whole match.
+func (m Match) Upto() string {
+	return m.Before + m.Text()
+}
+
+// Named returns the submatch of the subexpression (?P<name>re), or the
+// empty string if there is no such subexpression.
+func (m Match) Named(name string) string {
+	for i, n := range m.names {
+		if n != "" && n == name {
+			return m.Group(i)
+		}
+	}
+	return ""
+}
+
+// ExpectAny waits until one of cases matches, as classic Expect does with
+// many patterns. The pattern cases are tried in order on the input read
+// since the previous match: the first one that matches wins. If the input
+// ends (io.EOF) or the timeout expires, the EOF or the Timeout case matches,
+// if present.
+// ExpectAny returns the match and, if the case that matched has a handler,
+// the error returned by the handler. If no case matched, ExpectAny returns a
+// Match with Case -1 and the error, as [Expect.Expect].
+func (e *Expect) ExpectAny(cases ...Case) (Match, error) {
+	var matchers []Matcher
+	var idxs []int // idxs[i] is the index in cases of matchers[i].
+	for i, c := range cases {
+		if c.kind != casePattern {
+			continue
+		}
+		matcher := c.matcher
+		if matcher == nil {
+			reg, err := regexp.Compile(c.re)
+			if err != nil {
+				return Match{Case: -1}, err
+			}
+			matcher = reg
+		}
+		matchers = append(matchers, matcher)
+		idxs = append(idxs, i)
+	}
+
+	m, err := e.expect(matchers)
+	if err == nil {
+		m.Case = idxs[m.Case]
+		return m, cases[m.Case].handle(m)
+	}
+
+	kind := caseEOF
+	if errors.Is(err, ErrTimeout) {
+		kind = caseTimeout
+	} else if !errors.Is(err, io.EOF) {
+		return m, err
+	}
+	for i, c := range cases {
+		if c.kind == kind {
+			m.Case = i
+			return m, c.handle(m)
+		}
+	}
+	return m, err
+}
+
+func (c Case) handle(m Match) error {
+	if c.handler == nil {
+		return nil
+	}
+	return c.handler(m)
+}
@@ -0,0 +1,136 @@
+package expect_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-quicktest/qt"
+
+	"github.com/marco-m/otium/expect"
+)
+
+func TestExpectAny(t *testing.T) {
+	type testCase struct {
+		name       string
+		input      string
+		eof        bool
+		cases      []expect.Case
+		wantCase   int
+		wantGroups []string
+		wantErr    error
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		stdin, stdout, exp := expect.New(50*time.Millisecond, expect.MatchMaxDef)
+		defer stdin.Close()
+		go func() {
+			io.WriteString(stdout, tc.input)
+			if tc.eof {
+				stdout.Close()
+			}
+		}()
+
+		have, err := exp.ExpectAny(tc.cases...)
+
+		if tc.wantErr != nil {
+			qt.Assert(t, qt.ErrorIs(err, tc.wantErr))
+		} else {
+			qt.Assert(t, qt.IsNil(err))
+		}
+		qt.Assert(t, qt.Equals(have.Case, tc.wantCase))
+		qt.Assert(t, qt.DeepEquals(have.Groups, tc.wantGroups))
+	}
+
+	prompt := expect.Re(`\(top\)>> `, nil)
+	failure := expect.Re(`error: (.*)\n`, nil)
+
+	testCases := []testCase{
+		{
+			name:       "first case",
+			input:      "hello\n(top)>> ",
+			cases:      []expect.Case{prompt, failure},
+			wantCase:   0,
+			wantGroups: []string{"(top)>> "},
+		},
+		{
+			name:       "second case with submatch",
+			input:      "error: boom\n",
+			cases:      []expect.Case{prompt, failure},
+			wantCase:   1,
+			wantGroups: []string{"error: boom\n", "boom"},
+		},
+		{
+			name:       "cases are tried in order",
+			input:      "error: boom\n(top)>> ",
+			cases:      []expect.Case{prompt, failure},
+			wantCase:   0,
+			wantGroups: []string{"(top)>> "},
+		},
+		{
+			name:       "timeout case",
+			input:      "hello",
+			cases:      []expect.Case{prompt, expect.EOF(nil), expect.Timeout(nil)},
+			wantCase:   2,
+			wantGroups: []string{"hello"},
+		},
+		{
+			name:       "timeout without case",
+			input:      "hello",
+			cases:      []expect.Case{prompt},
+			wantCase:   -1,
+			wantGroups: []string{"hello"},
+			wantErr:    expect.ErrTimeout,
+		},
+		{
+			name:       "EOF",
+			input:      "hello",
+			eof:        true,
+			cases:      []expect.Case{prompt, expect.EOF(nil), expect.Timeout(nil)},
+			wantCase:   1,
+			wantGroups: []string{"hello"},
+		},
+		{
+			name:       "no case for EOF",
+			input:      "hello",
+			eof:        true,
+			cases:      []expect.Case{prompt},
+			wantCase:   -1,
+			wantGroups: []string{"hello"},
+			wantErr:    io.EOF,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
+
+func TestExpectAnyHandler(t *testing.T) {
+	sut := expect.Expect{Reader: strings.NewReader("error: boom\n")}
+	var handled string
+
+	have, err := sut.ExpectAny(
+		expect.Re(`\(top\)>> `, nil),
+		expect.Re(`error: (?P<msg>.*)\n`, func(m expect.Match) error {
+			handled = m.Named("msg")
+			return errors.New(handled)
+		}),
+	)
+
+	qt.Assert(t, qt.ErrorMatches(err, "boom"))
+	qt.Assert(t, qt.Equals(handled, "boom"))
+	qt.Assert(t, qt.Equals(have.Text(), "error: boom\n"))
+	qt.Assert(t, qt.Equals(have.Group(1), "boom"))
+	qt.Assert(t, qt.Equals(have.Group(2), ""))
+}
+
+func TestExpectAnyInvalidRegexp(t *testing.T) {
+	sut := expect.Expect{Reader: strings.NewReader("")}
+
+	_, err := sut.ExpectAny(expect.Re(`(`, nil))
+
+	qt.Assert(t, qt.ErrorMatches(err, "error parsing regexp.*"))
+}
@@ -5,13 +5,18 @@ import (
 	"io"
 	"os"
 	"regexp"
+	"sync"
 	"time"
 )
 
-var ErrTimeout = errors.New("expect: timeout")
+var (
+	ErrTimeout = errors.New("expect: timeout")
+	ErrClosed  = errors.New("expect: closed")
+)
 
 const MatchMaxDef = 2_000
 const TimeoutDef = 60 * time.Second
+const IdleDef = 100 * time.Millisecond
 
 // From the original Expect paper:
 // > The exact string matched (or read but unmatched, if a timeout occurred)
@@ -20,14 +25,51 @@ const TimeoutDef = 60 * time.Second
 // > reason most are surrounded by the * wildcard). However, more than 2000
 // > bytes of output can force earlier bytes to be "forgotten". This may be
 // > changed by setting the variable match_max.
+//
+// The zero value of Expect is usable once Reader is set. Fields Reader,
+// MatchMax, Log and LogStripANSI must not be changed after the first call to
+// Expect or Drain; Timeout can be changed between calls.
+//
+// Expect reads from Reader with a single background goroutine, started by
+// the first call to Expect or Drain, that lives until Reader returns an
+// error (for example io.EOF) or Close is called. Expect, Drain and Close are
+// safe for concurrent use.
 type Expect struct {
 	Reader   io.Reader
 	Writer   io.Writer
 	Timeout  time.Duration
 	MatchMax int
-	buf      []byte
-	match    []byte
-	offset   int
+	// Log, if set, receives the transcript of the session: one timestamped
+	// line for each chunk of bytes received from or sent to the SUT. See
+	// also [TLog] and [Replay].
+	Log io.Writer
+	// LogStripANSI, if true, removes the ANSI escape sequences from Log.
+	LogStripANSI bool
+	// StripANSI, if true, removes the ANSI escape sequences (colors, cursor
+	// movements, ...) from the input before matching it, so that the
+	// patterns do not depend on how the SUT draws on the terminal.
+	StripANSI bool
+	// Idle is the quiet period after which ExpectPrompt considers that the
+	// SUT is waiting for input; by default IdleDef.
+	Idle time.Duration
+
+	logMu sync.Mutex
+	once  sync.Once
+	mu    sync.Mutex
+	cond  *sync.Cond // Signaled when any of the fields below changes.
+	// buf[:offset] is the window of the input not yet matched. The window
+	// holds at most MatchMax bytes.
+	buf    []byte
+	offset int
+	// draining, if true, tells the reader to discard the input.
+	draining bool
+	drained  int64
+	closed   bool
+	lastRead time.Time // When the reader received data for the last time.
+	// err is the error returned by Reader; once set, the reader is gone.
+	err error
+	// done is closed when the reader is gone.
+	done chan struct{}
 }
 
 // New returns stdin as io.PipeReader, stdout as io.PipeWriter, and expect.
@@ -91,84 +133,234 @@ func NewFilePipe(timeout time.Duration, matchMax int) (*Expect, func()) {
 	return exp, cleanup
 }
 
-func (e *Expect) Expect(re string) (string, error) {
-	if e.MatchMax == 0 {
-		e.MatchMax = MatchMaxDef
-	}
-	if e.Timeout == 0 {
-		e.Timeout = TimeoutDef
-	}
-	if e.buf == nil {
+// start initializes e and starts the background reader, only once. It
+// initializes e under the lock, since Close can be called concurrently.
+func (e *Expect) start() {
+	e.once.Do(func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if e.MatchMax == 0 {
+			e.MatchMax = MatchMaxDef
+		}
+		if e.Timeout == 0 {
+			e.Timeout = TimeoutDef
+		}
+		if e.Idle == 0 {
+			e.Idle = IdleDef
+		}
 		e.buf = make([]byte, e.MatchMax)
+		e.lastRead = time.Now()
+		e.cond = sync.NewCond(&e.mu)
+		e.done = make(chan struct{})
+		go e.read()
+	})
+}
+
+// read is the background reader. It reads into the free space of the window,
+// so that no input is forgotten before Expect has tried to match it; when
+// the window is full, it waits for Expect to make room.
+func (e *Expect) read() {
+	defer close(e.done)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var tmp []byte
+	// pending is the trailing escape sequence, maybe incomplete, held back
+	// until the next read when stripping the ANSI escape sequences.
+	var pending []byte
+	for {
+		for !e.draining && !e.closed && e.offset == len(e.buf) {
+			e.cond.Wait()
+		}
+		if e.closed {
+			e.err = ErrClosed
+			e.cond.Broadcast()
+			return
+		}
+		size := len(e.buf) - e.offset - len(pending)
+		if e.draining {
+			size = len(e.buf)
+		}
+		if cap(tmp) < size {
+			tmp = make([]byte, size)
+		}
+
+		// Read without holding the lock, to let Expect and Close proceed.
+		e.mu.Unlock()
+		n, err := e.Reader.Read(tmp[:size])
+		e.mu.Lock()
+
+		// Only Expect and Drain change offset and both can only make room,
+		// so the n bytes always fit.
+		e.logEntry(dirRecv, tmp[:n])
+		if n > 0 {
+			e.lastRead = time.Now()
+		}
+		switch {
+		case e.draining:
+			e.drained += int64(n + len(pending))
+			pending = nil
+		case e.StripANSI:
+			data := append(pending, tmp[:n]...)
+			split := len(data)
+			if err == nil {
+				split = incompleteEscape(data)
+			}
+			e.offset += copy(e.buf[e.offset:], StripANSI(data[:split]))
+			pending = append([]byte(nil), data[split:]...)
+			if len(pending) >= len(e.buf)-e.offset {
+				// Not enough room to keep holding it back.
+				e.offset += copy(e.buf[e.offset:], pending)
+				pending = nil
+			}
+		default:
+			e.offset += copy(e.buf[e.offset:], tmp[:n])
+		}
+		if err != nil {
+			e.err = err
+			if e.closed {
+				e.err = ErrClosed
+			}
+			e.cond.Broadcast()
+			return
+		}
+		e.cond.Broadcast()
 	}
-	if e.match == nil {
-		e.match = make([]byte, e.MatchMax)
-	}
+}
 
+// Expect waits until the regular expression re matches the input read since
+// the previous match, and returns the matched string. Input before the match
+// is discarded; input after the match is kept for the next call.
+// If the timeout expires, Expect returns the input read so far (still kept
+// for the next call) and ErrTimeout. If Reader returns an error (for example
+// io.EOF), Expect returns the input read so far and the error.
+// To get the submatches, or to use a matcher other than a regular
+// expression, see [Expect.ExpectMatch].
+func (e *Expect) Expect(re string) (string, error) {
 	reg, err := regexp.Compile(re)
 	if err != nil {
 		return "", err
 	}
+	m, err := e.ExpectMatch(reg)
+	return m.Text(), err
+}
 
-	// We use a goroutine to avoid a blocking read. This does not happen for
-	// io.Pipe() but it does happen for os.Pipe()
-	type readInfo struct {
-		n   int
-		err error
-	}
-	readCh := make(chan readInfo, 1)
-
-	timer := time.NewTimer(e.Timeout)
-	var n int
-	// We handle in this way the error from the Read because in case of
-	// error, Read can still return n > 0 useful bytes.
-	for err == nil {
-		go func() {
-			n, err := e.Reader.Read(e.buf[e.offset:])
-			readCh <- readInfo{n: n, err: err}
-		}()
-		select {
-		case <-timer.C:
-			// Timeout: return the buffer contents.
-			// FIXME This leaks the goroutine and the channel :-(
-			return string(e.buf[:e.offset]), ErrTimeout
-		case rInfo := <-readCh:
-			n, err = rInfo.n, rInfo.err
-			e.offset += n
-			if loc := reg.FindIndex(e.buf[:e.offset]); loc != nil {
-				iL, iR := loc[0], loc[1]
-				copy(e.match, e.buf[iL:iR])
-				remain := e.offset - iR
-				if remain > 0 {
-					// Copy what is left after the match.
-					copy(e.buf, e.buf[iR:e.offset])
-					e.offset = remain
-				} else {
-					e.offset = 0
-				}
-				// Return the match.
-				return string(e.match[:iR-iL]), nil
+// ExpectMatch is like [Expect.Expect], with any Matcher, and returns also the
+// submatches and the input discarded before the match. If there is no match,
+// the returned Match contains the input read so far as the whole match.
+func (e *Expect) ExpectMatch(matcher Matcher) (Match, error) {
+	return e.expect([]Matcher{matcher})
+}
+
+// expect is the engine of the Expect methods. It waits until one of
+// matchers, tried in order, matches the input read since the previous match.
+// If there is no match, it returns a Match with Case -1 and the input read so
+// far as the whole match, with either ErrTimeout or the error returned by
+// Reader.
+func (e *Expect) expect(matchers []Matcher) (Match, error) {
+	e.start()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var timedOut bool
+	timer := time.AfterFunc(e.Timeout, func() {
+		e.mu.Lock()
+		timedOut = true
+		e.cond.Broadcast()
+		e.mu.Unlock()
+	})
+	defer timer.Stop()
+
+	for {
+		for idx, matcher := range matchers {
+			loc := matcher.FindSubmatchIndex(e.buf[:e.offset])
+			if loc == nil {
+				continue
 			}
-			if e.offset == cap(e.buf) {
-				// Reached the end of the buf. Copy half for overlap and start
-				// another cycle.
-				e.offset = cap(e.buf) / 2
-				copy(e.buf, e.buf[cap(e.buf)-e.offset:])
+			m := Match{
+				Case:   idx,
+				Before: string(e.buf[:loc[0]]),
+				Groups: make([]string, len(loc)/2),
+				names:  matcher.SubexpNames(),
 			}
+			for i := range m.Groups {
+				if loc[2*i] >= 0 {
+					m.Groups[i] = string(e.buf[loc[2*i]:loc[2*i+1]])
+				}
+			}
+			// Keep what is left after the match.
+			e.offset = copy(e.buf, e.buf[loc[1]:e.offset])
+			e.cond.Broadcast()
+			return m, nil
+		}
+		if e.offset == len(e.buf) {
+			// Reached the end of the buf. Keep half for overlap and start
+			// another cycle.
+			keep := len(e.buf) / 2
+			e.offset = copy(e.buf, e.buf[len(e.buf)-keep:])
+			e.cond.Broadcast()
+			continue
 		}
+		noMatch := Match{Case: -1, Groups: []string{string(e.buf[:e.offset])}}
+		if e.err != nil {
+			// EOF or any other read error: return the buffer contents.
+			return noMatch, e.err
+		}
+		if timedOut {
+			// Timeout: return the buffer contents.
+			return noMatch, ErrTimeout
+		}
+		e.cond.Wait()
 	}
-	// EOF or any other read error: return the buffer contents.
-	return string(e.buf[:e.offset+n]), err
 }
 
 func (e *Expect) Send(msg string) error {
+	e.logEntry(dirSend, []byte(msg))
 	_, err := e.Writer.Write([]byte(msg))
 	return err
 }
 
-// Drain reads and discards the Expect input until EOF or error.
+// Drain discards the Expect input, both the input already read and the one
+// still to read, until EOF or error. It returns the number of bytes
+// discarded; as io.Copy, it returns a nil error at EOF.
 func (e *Expect) Drain() (int64, error) {
-	return io.Copy(io.Discard, e.Reader)
+	e.start()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.drained, e.offset = int64(e.offset), 0
+	e.draining = true
+	e.cond.Broadcast()
+	for e.err == nil {
+		e.cond.Wait()
+	}
+	e.draining = false
+	if e.err == io.EOF {
+		return e.drained, nil
+	}
+	return e.drained, e.err
+}
+
+// Close stops the background reader and, if Reader is an io.Closer, closes
+// it, to unblock a pending read. After Close, Expect and Drain return the
+// input read so far and ErrClosed, unless Reader already returned an error.
+func (e *Expect) Close() error {
+	e.mu.Lock()
+	e.closed = true
+	if e.cond != nil {
+		e.cond.Broadcast()
+	}
+	if e.err == nil && e.cond == nil {
+		// The reader has never been started.
+		e.err = ErrClosed
+	}
+	e.mu.Unlock()
+
+	if closer, ok := e.Reader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
 }
 
 // FixedReader reads from Reader always a fixed amount of bytes, N.
@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -342,3 +343,106 @@ func TestExpectSimulateSutOsPipeTimeout(t *testing.T) {
 	err = <-asyncErr
 	qt.Assert(t, qt.IsNil(err))
 }
+
+func TestExpectMatchAfterTimeout(t *testing.T) {
+	stdin, stdout, exp := expect.New(50*time.Millisecond, expect.MatchMaxDef)
+	defer stdin.Close()
+
+	go func() {
+		fmt.Fprint(stdout, "12345")
+		time.Sleep(100 * time.Millisecond)
+		fmt.Fprint(stdout, "HELLO")
+		stdout.Close()
+	}()
+
+	have, err := exp.Expect(`HELLO`)
+	qt.Assert(t, qt.ErrorIs(err, expect.ErrTimeout))
+	qt.Assert(t, qt.Equals(have, "12345"))
+
+	// The input read before the timeout is kept for the next call.
+	exp.Timeout = expect.TimeoutDef
+	have, err = exp.Expect(`.*HELLO`)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(have, "12345HELLO"))
+}
+
+func TestExpectDrain(t *testing.T) {
+	sut := expect.Expect{Reader: strings.NewReader("0123456789")}
+
+	have, err := sut.Expect(`.*3`)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(have, "0123"))
+
+	n, err := sut.Drain()
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(n, int64(6)))
+
+	have, err = sut.Expect(`.*`)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(have, ""))
+}
+
+func TestExpectCloseStopsTheReader(t *testing.T) {
+	stdin, stdout, exp := expect.New(50*time.Millisecond, expect.MatchMaxDef)
+	defer stdin.Close()
+	defer stdout.Close()
+
+	_, err := exp.Expect(`never`)
+	qt.Assert(t, qt.ErrorIs(err, expect.ErrTimeout))
+
+	qt.Assert(t, qt.IsNil(exp.Close()))
+	_, err = exp.Expect(`never`)
+	qt.Assert(t, qt.ErrorIs(err, expect.ErrClosed))
+
+	select {
+	case <-exp.ReaderDone():
+	case <-time.After(time.Second):
+		t.Fatal("the reader is still running after Close")
+	}
+}
+
+func TestExpectCloseConcurrentWithStart(t *testing.T) {
+	stdin, stdout, exp := expect.New(time.Second, expect.MatchMaxDef)
+	defer stdin.Close()
+	defer stdout.Close()
+
+	// Run with -race: Close must not race with the initialization done by
+	// the first Expect.
+	errc := make(chan error)
+	go func() {
+		_, err := exp.Expect(`never`)
+		errc <- err
+	}()
+	qt.Assert(t, qt.IsNil(exp.Close()))
+
+	qt.Assert(t, qt.ErrorIs(<-errc, expect.ErrClosed))
+}
+
+func TestExpectConcurrentUse(t *testing.T) {
+	stdin, stdout, exp := expect.New(time.Second, 10)
+	defer stdin.Close()
+
+	go func() {
+		for i := 0; i < 100; i++ {
+			fmt.Fprintf(stdout, "line %d\n", i)
+		}
+		stdout.Close()
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if _, err := exp.Expect(`line \d+\n`); err != nil {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	_, err := exp.Drain()
+	qt.Assert(t, qt.IsNil(err))
+}
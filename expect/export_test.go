@@ -0,0 +1,9 @@
+package expect
+
+// ReaderDone returns a channel closed when the background reader of e is
+// gone. It must be called after the first call to Expect or Drain.
+func (e *Expect) ReaderDone() <-chan struct{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.done
+}
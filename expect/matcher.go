@@ -0,0 +1,78 @@
+package expect
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// Matcher finds a match in the input. A *regexp.Regexp is a Matcher; see
+// also [Literal], [Glob] and [Func].
+type Matcher interface {
+	// FindSubmatchIndex returns the locations of the leftmost match in buf
+	// and of its submatches, as [regexp.Regexp.FindSubmatchIndex], or nil if
+	// there is no match.
+	FindSubmatchIndex(buf []byte) []int
+	// SubexpNames returns the names of the submatches, as
+	// [regexp.Regexp.SubexpNames].
+	SubexpNames() []string
+}
+
+// Literal returns a Matcher of the exact text s, with no special characters.
+func Literal(s string) Matcher {
+	return literal(s)
+}
+
+type literal string
+
+func (lit literal) FindSubmatchIndex(buf []byte) []int {
+	i := bytes.Index(buf, []byte(lit))
+	if i < 0 {
+		return nil
+	}
+	return []int{i, i + len(lit)}
+}
+
+func (lit literal) SubexpNames() []string {
+	return []string{""}
+}
+
+// Glob returns a Matcher of the shell-like pattern, where '*' matches any
+// sequence of characters (including newlines), '?' matches any single
+// character and all the other characters match themselves. As with the
+// regular expressions, the pattern is not anchored: "*(top)>> " matches the
+// input up to the prompt.
+func Glob(pattern string) Matcher {
+	var re strings.Builder
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			re.WriteString(`(?s:.*)`)
+		case '?':
+			re.WriteString(`(?s:.)`)
+		default:
+			re.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return regexp.MustCompile(re.String())
+}
+
+// Func returns a Matcher that calls f, which returns the start and end of the
+// match in buf and true, or false if there is no match.
+func Func(f func(buf []byte) (start, end int, ok bool)) Matcher {
+	return funcMatcher(f)
+}
+
+type funcMatcher func(buf []byte) (int, int, bool)
+
+func (fn funcMatcher) FindSubmatchIndex(buf []byte) []int {
+	start, end, ok := fn(buf)
+	if !ok {
+		return nil
+	}
+	return []int{start, end}
+}
+
+func (fn funcMatcher) SubexpNames() []string {
+	return []string{""}
+}
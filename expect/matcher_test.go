@@ -0,0 +1,104 @@
+package expect_test
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/go-quicktest/qt"
+
+	"github.com/marco-m/otium/expect"
+)
+
+func TestExpectMatch(t *testing.T) {
+	type testCase struct {
+		name       string
+		matcher    expect.Matcher
+		wantBefore string
+		wantGroups []string
+		wantNext   string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		sut := expect.Expect{
+			Reader: strings.NewReader("fruit: banana (x2)\n(top)>> next\n"),
+		}
+
+		have, err := sut.ExpectMatch(tc.matcher)
+
+		qt.Assert(t, qt.IsNil(err))
+		qt.Assert(t, qt.Equals(have.Case, 0))
+		qt.Assert(t, qt.Equals(have.Before, tc.wantBefore))
+		qt.Assert(t, qt.DeepEquals(have.Groups, tc.wantGroups))
+		next, err := sut.Expect(`(?s).*`)
+		qt.Assert(t, qt.IsNil(err))
+		qt.Assert(t, qt.Equals(next, tc.wantNext))
+	}
+
+	testCases := []testCase{
+		{
+			name:       "regexp with submatches",
+			matcher:    regexp.MustCompile(`fruit: (\w+) \(x(\d)\)`),
+			wantGroups: []string{"fruit: banana (x2)", "banana", "2"},
+			wantNext:   "\n(top)>> next\n",
+		},
+		{
+			name:       "literal",
+			matcher:    expect.Literal("(top)>> "),
+			wantBefore: "fruit: banana (x2)\n",
+			wantGroups: []string{"(top)>> "},
+			wantNext:   "next\n",
+		},
+		{
+			name:       "glob",
+			matcher:    expect.Glob("*(x?)"),
+			wantGroups: []string{"fruit: banana (x2)"},
+			wantNext:   "\n(top)>> next\n",
+		},
+		{
+			name:       "glob across lines",
+			matcher:    expect.Glob("banana*>> "),
+			wantBefore: "fruit: ",
+			wantGroups: []string{"banana (x2)\n(top)>> "},
+			wantNext:   "next\n",
+		},
+		{
+			name: "func",
+			matcher: expect.Func(func(buf []byte) (int, int, bool) {
+				i := bytes.IndexByte(buf, '\n')
+				return 0, i + 1, i >= 0
+			}),
+			wantGroups: []string{"fruit: banana (x2)\n"},
+			wantNext:   "(top)>> next\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
+
+func TestExpectMatchNamed(t *testing.T) {
+	sut := expect.Expect{Reader: strings.NewReader("fruit: banana\n")}
+
+	have, err := sut.ExpectMatch(regexp.MustCompile(`(?P<key>\w+): (?P<val>\w+)`))
+
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(have.Named("key"), "fruit"))
+	qt.Assert(t, qt.Equals(have.Named("val"), "banana"))
+	qt.Assert(t, qt.Equals(have.Named("missing"), ""))
+}
+
+func TestExpectAnyOn(t *testing.T) {
+	sut := expect.Expect{Reader: strings.NewReader("error: boom\n(top)>> ")}
+
+	have, err := sut.ExpectAny(
+		expect.On(expect.Literal("(top)>> "), nil),
+		expect.EOF(nil),
+	)
+
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(have.Case, 0))
+	qt.Assert(t, qt.Equals(have.Upto(), "error: boom\n(top)>> "))
+}
@@ -0,0 +1,126 @@
+package expect
+
+import (
+	"bytes"
+	"time"
+)
+
+// Key is a key of the keyboard, as sent by a terminal in raw mode. See
+// [Expect.SendKey].
+type Key string
+
+// Keys for [Expect.SendKey]. The arrows are in the ANSI (normal cursor) mode.
+const (
+	KeyEnter     Key = "\r"
+	KeyTab       Key = "\t"
+	KeyBackspace Key = "\x7f"
+	KeyEsc       Key = "\x1b"
+	KeyCtrlC     Key = "\x03"
+	KeyCtrlD     Key = "\x04"
+	KeyCtrlR     Key = "\x12"
+	KeyUp        Key = "\x1b[A"
+	KeyDown      Key = "\x1b[B"
+	KeyRight     Key = "\x1b[C"
+	KeyLeft      Key = "\x1b[D"
+)
+
+// SendKey sends keys, in order. It is meant for a SUT reading from a
+// terminal, for example started with [Spawn], to test completion (KeyTab),
+// history (KeyUp, KeyCtrlR) and interruption (KeyCtrlC, KeyCtrlD).
+func (e *Expect) SendKey(keys ...Key) error {
+	var msg string
+	for _, key := range keys {
+		msg += string(key)
+	}
+	return e.Send(msg)
+}
+
+// WaitIdle waits until the SUT has not written anything for d, and then
+// returns all the input read since the previous match, consuming it. It is
+// useful when the exact output is not known in advance, for example after
+// KeyTab. If d is zero, it uses [Expect.Idle]. If the timeout expires, it
+// returns the input read so far and ErrTimeout. If Reader has returned an
+// error (for example io.EOF), the SUT is gone: it returns the input read so
+// far, consuming it, and the error.
+func (e *Expect) WaitIdle(d time.Duration) (string, error) {
+	return e.waitIdle(d, func(buf []byte) bool { return true })
+}
+
+// ExpectPrompt waits until the input ends with prompt and the SUT has not
+// written anything for [Expect.Idle], that is, until the SUT is waiting for
+// input at prompt. It returns all the input read since the previous match,
+// consuming it. Differently from Expect, it is not fooled by a prompt
+// followed by more output, for example when a line editor redraws the line.
+// Consider setting [Expect.StripANSI]. If Reader has returned an error (for
+// example io.EOF), the SUT is not waiting for input: ExpectPrompt returns
+// the input read so far and the error, consuming the input only if it ends
+// with prompt.
+func (e *Expect) ExpectPrompt(prompt string) (string, error) {
+	return e.waitIdle(0, func(buf []byte) bool {
+		return bytes.HasSuffix(buf, []byte(prompt))
+	})
+}
+
+// waitIdle waits until the SUT has not written anything for d (if zero,
+// for [Expect.Idle]) and done returns true for the window, then returns the
+// window, consuming it. Once the reader has ended, it returns its error, even
+// if done returns true.
+func (e *Expect) waitIdle(d time.Duration, done func(buf []byte) bool) (string, error) {
+	e.start()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if d == 0 {
+		// Read under the lock, after start has set the default.
+		d = e.Idle
+	}
+
+	var timedOut bool
+	timer := time.AfterFunc(e.Timeout, func() {
+		e.mu.Lock()
+		timedOut = true
+		e.cond.Broadcast()
+		e.mu.Unlock()
+	})
+	defer timer.Stop()
+
+	for {
+		idle := time.Since(e.lastRead)
+		if e.err != nil || idle >= d {
+			if done(e.buf[:e.offset]) {
+				have := string(e.buf[:e.offset])
+				e.offset = 0
+				e.cond.Broadcast()
+				return have, e.err
+			}
+			if e.err != nil {
+				return string(e.buf[:e.offset]), e.err
+			}
+		}
+		if e.offset == len(e.buf) {
+			// Reached the end of the buf. Keep half for overlap and start
+			// another cycle.
+			keep := len(e.buf) / 2
+			e.offset = copy(e.buf, e.buf[len(e.buf)-keep:])
+			e.cond.Broadcast()
+			continue
+		}
+		if timedOut {
+			return string(e.buf[:e.offset]), ErrTimeout
+		}
+		// Wake up at the end of the quiet period, if nothing else happens
+		// before.
+		wait := d - idle
+		if wait <= 0 {
+			wait = d
+		}
+		wake := time.AfterFunc(wait, func() {
+			e.mu.Lock()
+			e.cond.Broadcast()
+			e.mu.Unlock()
+		})
+		e.cond.Wait()
+		wake.Stop()
+	}
+}
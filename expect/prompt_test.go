@@ -0,0 +1,141 @@
+package expect_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-quicktest/qt"
+
+	"github.com/marco-m/otium/expect"
+)
+
+func TestExpectStripANSI(t *testing.T) {
+	type testCase struct {
+		name  string
+		chunk int
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		sut := expect.Expect{
+			Reader: &expect.FixedReader{
+				Reader: strings.NewReader("\x1b[1mhello\x1b[0m\r\n\x1b[K(top)>> \x1b[?2004h"),
+				N:      tc.chunk,
+			},
+			StripANSI: true,
+		}
+
+		have, err := sut.ExpectMatch(expect.Literal("(top)>> "))
+		qt.Assert(t, qt.IsNil(err))
+		qt.Assert(t, qt.Equals(have.Upto(), "hello\r\n(top)>> "))
+
+		rest, err := sut.Expect(`never`)
+		qt.Assert(t, qt.ErrorIs(err, io.EOF))
+		qt.Assert(t, qt.Equals(rest, ""))
+	}
+
+	testCases := []testCase{
+		{name: "one read", chunk: 1000},
+		{name: "escapes split across reads", chunk: 1},
+		{name: "odd reads", chunk: 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
+
+func TestExpectWaitIdle(t *testing.T) {
+	stdin, stdout, exp := expect.New(time.Second, expect.MatchMaxDef)
+	defer stdin.Close()
+	go func() {
+		io.WriteString(stdout, "a")
+		time.Sleep(10 * time.Millisecond)
+		io.WriteString(stdout, "b")
+	}()
+
+	have, err := exp.WaitIdle(100 * time.Millisecond)
+
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(have, "ab"))
+}
+
+func TestExpectWaitIdleReturnsTheReaderError(t *testing.T) {
+	exp := expect.Expect{Reader: strings.NewReader("bye\n"), Timeout: time.Second}
+
+	have, err := exp.WaitIdle(time.Minute)
+
+	qt.Assert(t, qt.ErrorIs(err, io.EOF))
+	qt.Assert(t, qt.Equals(have, "bye\n"))
+
+	// The input has been consumed.
+	have, err = exp.WaitIdle(time.Minute)
+
+	qt.Assert(t, qt.ErrorIs(err, io.EOF))
+	qt.Assert(t, qt.Equals(have, ""))
+}
+
+func TestExpectPromptAfterEOF(t *testing.T) {
+	exp := expect.Expect{Reader: strings.NewReader("(top)>> "), Timeout: time.Second}
+
+	have, err := exp.ExpectPrompt("(top)>> ")
+
+	qt.Assert(t, qt.ErrorIs(err, io.EOF))
+	qt.Assert(t, qt.Equals(have, "(top)>> "))
+}
+
+func TestExpectPromptWaitsForTheLastPrompt(t *testing.T) {
+	stdin, stdout, exp := expect.New(time.Second, expect.MatchMaxDef)
+	defer stdin.Close()
+	exp.Idle = 50 * time.Millisecond
+	go func() {
+		// A line editor drawing the prompt and then redrawing it.
+		io.WriteString(stdout, "(top)>> ")
+		time.Sleep(10 * time.Millisecond)
+		io.WriteString(stdout, "\r(top)>> ")
+	}()
+
+	have, err := exp.ExpectPrompt("(top)>> ")
+
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(have, "(top)>> \r(top)>> "))
+}
+
+func TestExpectPromptDefaultIdle(t *testing.T) {
+	stdin, stdout, exp := expect.New(time.Second, expect.MatchMaxDef)
+	defer stdin.Close()
+	go func() {
+		// A line editor drawing the prompt and then redrawing it, well
+		// within IdleDef.
+		io.WriteString(stdout, "(top)>> ")
+		time.Sleep(expect.IdleDef / 10)
+		io.WriteString(stdout, "\r(top)>> ")
+	}()
+
+	have, err := exp.ExpectPrompt("(top)>> ")
+
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(have, "(top)>> \r(top)>> "))
+}
+
+func TestExpectPromptTimeout(t *testing.T) {
+	stdin, stdout, exp := expect.New(100*time.Millisecond, expect.MatchMaxDef)
+	defer stdin.Close()
+	go io.WriteString(stdout, "(top)>> and more")
+
+	have, err := exp.ExpectPrompt("(top)>> ")
+
+	qt.Assert(t, qt.ErrorIs(err, expect.ErrTimeout))
+	qt.Assert(t, qt.Equals(have, "(top)>> and more"))
+}
+
+func TestSendKey(t *testing.T) {
+	var sent strings.Builder
+	exp := expect.Expect{Writer: &sent}
+
+	err := exp.SendKey(expect.KeyUp, expect.KeyTab, expect.KeyCtrlC)
+
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(sent.String(), "\x1b[A\t\x03"))
+}
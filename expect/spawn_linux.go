@@ -0,0 +1,130 @@
+//go:build linux
+
+package expect
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// Spawn starts cmd attached to a new pseudo-terminal (PTY), as its
+// controlling terminal, and returns the Expect to communicate with it. Since
+// cmd sees a real terminal, libraries such as liner enable line editing, tab
+// completion and Ctrl-C handling, as in production.
+//
+// cmd must not have Stdin, Stdout, Stderr nor SysProcAttr set. If the
+// environment of cmd does not set TERM, Spawn sets it to xterm. The terminal
+// is 80x24.
+//
+// The caller must call cmd.Wait to reap the process and Expect.Close to
+// release the PTY. When the process exits, Expect returns io.EOF.
+func Spawn(cmd *exec.Cmd) (*Expect, error) {
+	if cmd.Stdin != nil || cmd.Stdout != nil || cmd.Stderr != nil {
+		return nil, errors.New("spawn: cmd has Stdin, Stdout or Stderr set")
+	}
+	if cmd.SysProcAttr != nil {
+		return nil, errors.New("spawn: cmd has SysProcAttr set")
+	}
+	ptmx, tty, err := openPTY()
+	if err != nil {
+		return nil, fmt.Errorf("spawn: %w", err)
+	}
+	defer tty.Close()
+	if err := setWinsize(ptmx, 24, 80); err != nil {
+		ptmx.Close()
+		return nil, fmt.Errorf("spawn: %w", err)
+	}
+
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	if !hasTerm(cmd.Env) {
+		cmd.Env = append(cmd.Env, "TERM=xterm")
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = tty, tty, tty
+	// The child becomes the leader of a new session, with the PTY (its
+	// stdin, fd 0) as controlling terminal.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
+	if err := cmd.Start(); err != nil {
+		ptmx.Close()
+		return nil, fmt.Errorf("spawn: %w", err)
+	}
+
+	return &Expect{
+		Reader: &ptyReader{ptmx},
+		Writer: ptmx,
+	}, nil
+}
+
+func hasTerm(env []string) bool {
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "TERM=") && kv != "TERM=" {
+			return true
+		}
+	}
+	return false
+}
+
+// openPTY opens a new PTY, returning its master and slave sides.
+func openPTY() (*os.File, *os.File, error) {
+	ptmx, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	var unlock int32
+	if err := ioctl(ptmx, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		ptmx.Close()
+		return nil, nil, fmt.Errorf("unlockpt: %w", err)
+	}
+	var n uint32
+	if err := ioctl(ptmx, syscall.TIOCGPTN, unsafe.Pointer(&n)); err != nil {
+		ptmx.Close()
+		return nil, nil, fmt.Errorf("ptsname: %w", err)
+	}
+	tty, err := os.OpenFile("/dev/pts/"+strconv.Itoa(int(n)),
+		os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		ptmx.Close()
+		return nil, nil, err
+	}
+	return ptmx, tty, nil
+}
+
+func setWinsize(fi *os.File, rows, cols uint16) error {
+	ws := struct{ Row, Col, X, Y uint16 }{Row: rows, Col: cols}
+	if err := ioctl(fi, syscall.TIOCSWINSZ, unsafe.Pointer(&ws)); err != nil {
+		return fmt.Errorf("set window size: %w", err)
+	}
+	return nil
+}
+
+func ioctl(fi *os.File, req uint, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fi.Fd(), uintptr(req),
+		uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// ptyReader adapts the master side of a PTY to the io.Reader semantics: on
+// Linux, once the slave side is closed (the process exited), reading returns
+// EIO instead of io.EOF.
+type ptyReader struct {
+	*os.File
+}
+
+func (pr *ptyReader) Read(p []byte) (int, error) {
+	n, err := pr.File.Read(p)
+	if errors.Is(err, syscall.EIO) {
+		err = io.EOF
+	}
+	return n, err
+}
@@ -0,0 +1,132 @@
+//go:build linux
+
+package expect_test
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-quicktest/qt"
+
+	"github.com/marco-m/otium/expect"
+)
+
+// buildExample builds the example called name and returns the path to the
+// executable.
+func buildExample(t *testing.T, name string) string {
+	t.Helper()
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go not found in PATH")
+	}
+	exe := filepath.Join(t.TempDir(), name)
+	cmd := exec.Command(goBin, "build", "-o", exe, "../examples/"+name)
+	out, err := cmd.CombinedOutput()
+	qt.Assert(t, qt.IsNil(err), qt.Commentf("%s", out))
+	return exe
+}
+
+func TestSpawnTabCompletionAndCtrlC(t *testing.T) {
+	exe := buildExample(t, "cliflags")
+	cmd := exec.Command(exe, "--no-history")
+	// Do not pollute the state directory of the user running the tests.
+	cmd.Env = append(os.Environ(), "XDG_STATE_HOME="+t.TempDir())
+	exp, err := expect.Spawn(cmd)
+	qt.Assert(t, qt.IsNil(err))
+	defer exp.Close()
+	exp.Timeout = 10 * time.Second
+
+	_, err = exp.ExpectMatch(expect.Literal("(top)>> "))
+	qt.Assert(t, qt.IsNil(err))
+
+	// Tab completion works only with a real terminal.
+	err = exp.Send("vari\t")
+	qt.Assert(t, qt.IsNil(err))
+	_, err = exp.ExpectMatch(expect.Literal("variables"))
+	qt.Assert(t, qt.IsNil(err))
+	err = exp.Send("\r")
+	qt.Assert(t, qt.IsNil(err))
+	_, err = exp.ExpectMatch(expect.Literal("fruit"))
+	qt.Assert(t, qt.IsNil(err))
+	_, err = exp.ExpectMatch(expect.Literal("(top)>> "))
+	qt.Assert(t, qt.IsNil(err))
+
+	// Ctrl-C before starting the procedure quits without confirmation.
+	err = exp.Send("\x03")
+	qt.Assert(t, qt.IsNil(err))
+	_, err = exp.Drain()
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.IsNil(cmd.Wait()))
+}
+
+func TestSpawnEOF(t *testing.T) {
+	cmd := exec.Command("echo", "hello")
+	exp, err := expect.Spawn(cmd)
+	qt.Assert(t, qt.IsNil(err))
+	defer exp.Close()
+
+	have, err := exp.Expect(`never`)
+
+	qt.Assert(t, qt.ErrorIs(err, io.EOF))
+	qt.Assert(t, qt.Equals(have, "hello\r\n"))
+	qt.Assert(t, qt.IsNil(cmd.Wait()))
+}
+
+func TestSpawnRejectsRedirectedCmd(t *testing.T) {
+	cmd := exec.Command("echo", "hello")
+	cmd.Stdout = io.Discard
+
+	_, err := expect.Spawn(cmd)
+
+	qt.Assert(t, qt.ErrorMatches(err, "spawn: cmd has Stdin, Stdout or Stderr set"))
+}
+
+func TestSpawnKeys(t *testing.T) {
+	exe := buildExample(t, "cliflags")
+	cmd := exec.Command(exe)
+	// Do not pollute the state directory of the user running the tests.
+	cmd.Env = append(os.Environ(), "XDG_STATE_HOME="+t.TempDir())
+	exp, err := expect.Spawn(cmd)
+	qt.Assert(t, qt.IsNil(err))
+	defer exp.Close()
+	exp.Timeout = 10 * time.Second
+	exp.StripANSI = true
+
+	_, err = exp.ExpectPrompt("(top)>> ")
+	qt.Assert(t, qt.IsNil(err))
+
+	// Two tabs list the candidates.
+	qt.Assert(t, qt.IsNil(exp.SendKey("s", expect.KeyTab, expect.KeyTab)))
+	have, err := exp.WaitIdle(200 * time.Millisecond)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.StringContains(have, "set"))
+	qt.Assert(t, qt.StringContains(have, "show"))
+	qt.Assert(t, qt.StringContains(have, "skip"))
+
+	// Clear the line and run a command.
+	qt.Assert(t, qt.IsNil(exp.SendKey(expect.KeyBackspace, expect.KeyBackspace)))
+	qt.Assert(t, qt.IsNil(exp.Send("list")))
+	qt.Assert(t, qt.IsNil(exp.SendKey(expect.KeyEnter)))
+	have, err = exp.ExpectPrompt("(top)>> ")
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.StringContains(have, "Two variables"))
+
+	// The up arrow recalls the previous command.
+	qt.Assert(t, qt.IsNil(exp.SendKey(expect.KeyUp)))
+	have, err = exp.WaitIdle(200 * time.Millisecond)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.StringContains(have, "list"))
+
+	// Ctrl-D on an empty line quits.
+	for range "list" {
+		qt.Assert(t, qt.IsNil(exp.SendKey(expect.KeyBackspace)))
+	}
+	qt.Assert(t, qt.IsNil(exp.SendKey(expect.KeyCtrlD)))
+	_, err = exp.Drain()
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.IsNil(cmd.Wait()))
+}
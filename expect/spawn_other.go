@@ -0,0 +1,14 @@
+//go:build !linux
+
+package expect
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// Spawn starts cmd attached to a new pseudo-terminal. It is supported only
+// on Linux.
+func Spawn(cmd *exec.Cmd) (*Expect, error) {
+	return nil, errors.New("spawn: pseudo-terminals are supported only on Linux")
+}
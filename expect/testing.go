@@ -0,0 +1,162 @@
+package expect
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// T is an Expect bound to a test and to a system under test (SUT) running in
+// its own goroutine. Its Must methods fail the test, with a dump of the
+// unmatched input, instead of returning an error. Create it with [NewT].
+type T struct {
+	*Expect
+	t    testing.TB
+	done chan struct{}
+	err  error // Returned by the SUT; valid once done is closed.
+}
+
+// NewT runs sut in a new goroutine, connected with pipes to the returned T.
+// When sut returns, its stdout is closed, so that the test sees io.EOF.
+// At the end of the test, T closes the pipes (which unblocks a SUT still
+// reading or writing) and waits for sut to return, failing the test if it
+// does not within the timeout.
+func NewT(t testing.TB, timeout time.Duration,
+	sut func(stdin io.Reader, stdout io.Writer) error,
+) *T {
+	t.Helper()
+	if timeout == 0 {
+		timeout = TimeoutDef
+	}
+	stdinRd, stdoutWr, exp := New(timeout, MatchMaxDef)
+	et := &T{Expect: exp, t: t, done: make(chan struct{})}
+	// Start reading now, so that the SUT is not blocked writing while the
+	// test is sending.
+	exp.start()
+	go func() {
+		defer close(et.done)
+		et.err = sut(stdinRd, stdoutWr)
+		stdoutWr.Close()
+	}()
+	t.Cleanup(func() {
+		exp.Writer.(io.Closer).Close()
+		exp.Close()
+		select {
+		case <-et.done:
+		case <-time.After(exp.Timeout):
+			t.Errorf("expect: the SUT is still running at the end of the test")
+		}
+	})
+	return et
+}
+
+// MustExpect is like [Expect.Expect], but fails the test on error.
+func (et *T) MustExpect(re string) string {
+	et.t.Helper()
+	have, err := et.Expect.Expect(re)
+	if err != nil {
+		et.fatal(err, "`"+re+"`", have)
+	}
+	return have
+}
+
+// MustExpectMatch is like [Expect.ExpectMatch], but fails the test on error.
+func (et *T) MustExpectMatch(matcher Matcher) Match {
+	et.t.Helper()
+	m, err := et.Expect.ExpectMatch(matcher)
+	if err != nil {
+		et.fatal(err, describe(matcher), m.Text())
+	}
+	return m
+}
+
+// MustExpectPrompt is like [Expect.ExpectPrompt], but fails the test on
+// error.
+func (et *T) MustExpectPrompt(prompt string) string {
+	et.t.Helper()
+	have, err := et.Expect.ExpectPrompt(prompt)
+	if err != nil {
+		et.fatal(err, "prompt "+strconv.Quote(prompt), have)
+	}
+	return have
+}
+
+// MustSendKey is like [Expect.SendKey], but fails the test on error.
+func (et *T) MustSendKey(keys ...Key) {
+	et.t.Helper()
+	if err := et.Expect.SendKey(keys...); err != nil {
+		et.t.Fatalf("expect: send keys %q: %s", keys, err)
+	}
+}
+
+// MustSend is like [Expect.Send], but fails the test on error.
+func (et *T) MustSend(msg string) {
+	et.t.Helper()
+	if err := et.Expect.Send(msg); err != nil {
+		et.t.Fatalf("expect: send %q: %s", msg, err)
+	}
+}
+
+// SendLine sends line followed by a newline, failing the test on error.
+func (et *T) SendLine(line string) {
+	et.t.Helper()
+	et.MustSend(line + "\n")
+}
+
+// ExpectEOF waits for the end of the output of the SUT and returns the
+// output not yet matched. It fails the test on timeout or on any other
+// error.
+func (et *T) ExpectEOF() string {
+	et.t.Helper()
+	// A regular expression that never matches.
+	have, err := et.Expect.Expect(`$.^`)
+	if !errors.Is(err, io.EOF) {
+		et.fatal(err, "EOF", have)
+	}
+	return have
+}
+
+// Wait waits for the SUT to return and returns its error. It fails the test
+// if the SUT does not return within the timeout.
+func (et *T) Wait() error {
+	et.t.Helper()
+	select {
+	case <-et.done:
+		return et.err
+	case <-time.After(et.Expect.Timeout):
+		et.t.Fatalf("expect: the SUT did not return within %s", et.Expect.Timeout)
+		return nil
+	}
+}
+
+// fatal fails the test, dumping the unmatched input one quoted line at a
+// time, so that invisible characters are visible and the dump can be
+// compared line by line.
+func (et *T) fatal(err error, want string, unmatched string) {
+	et.t.Helper()
+	var dump strings.Builder
+	for _, line := range strings.SplitAfter(unmatched, "\n") {
+		if line != "" {
+			fmt.Fprintf(&dump, "    %s\n", strconv.Quote(line))
+		}
+	}
+	et.t.Fatalf("want %s: %s\nunmatched input (%d bytes):\n%s",
+		want, err, len(unmatched), dump.String())
+}
+
+// describe returns a description of matcher for the error messages.
+func describe(matcher Matcher) string {
+	switch m := matcher.(type) {
+	case *regexp.Regexp:
+		return "`" + m.String() + "`"
+	case literal:
+		return strconv.Quote(string(m))
+	default:
+		return fmt.Sprintf("%T", matcher)
+	}
+}
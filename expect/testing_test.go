@@ -0,0 +1,95 @@
+package expect_test
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-quicktest/qt"
+
+	"github.com/marco-m/otium/expect"
+)
+
+// echo is a SUT that echoes each line, until "bye".
+func echo(stdin io.Reader, stdout io.Writer) error {
+	scanner := bufio.NewScanner(stdin)
+	for {
+		fmt.Fprint(stdout, "> ")
+		if !scanner.Scan() {
+			return errors.New("no bye")
+		}
+		if scanner.Text() == "bye" {
+			fmt.Fprintln(stdout, "see you")
+			return nil
+		}
+		fmt.Fprintln(stdout, scanner.Text())
+	}
+}
+
+func TestNewTSuccess(t *testing.T) {
+	exp := expect.NewT(t, time.Second, echo)
+
+	exp.MustExpect(`> `)
+	exp.SendLine("hello")
+	have := exp.MustExpectMatch(expect.Literal("> "))
+	qt.Assert(t, qt.Equals(have.Before, "hello\n"))
+	exp.MustSend("bye\n")
+
+	qt.Assert(t, qt.Equals(exp.ExpectEOF(), "see you\n"))
+	qt.Assert(t, qt.IsNil(exp.Wait()))
+}
+
+func TestNewTSutError(t *testing.T) {
+	exp := expect.NewT(t, time.Second, echo)
+
+	exp.MustExpect(`> `)
+	exp.Writer.(io.Closer).Close()
+
+	qt.Assert(t, qt.ErrorMatches(exp.Wait(), "no bye"))
+}
+
+// The cleanup unblocks the SUT, still waiting for input.
+func TestNewTCleanupUnblocksTheSut(t *testing.T) {
+	exp := expect.NewT(t, time.Second, echo)
+
+	exp.MustExpect(`> `)
+}
+
+// fakeT records the failures of the helpers under test.
+type fakeT struct {
+	testing.TB
+	fatal string
+}
+
+func (ft *fakeT) Helper() {}
+
+func (ft *fakeT) Fatalf(format string, args ...any) {
+	ft.fatal = fmt.Sprintf(format, args...)
+	runtime.Goexit()
+}
+
+func TestNewTMustExpectFailure(t *testing.T) {
+	ft := &fakeT{TB: t}
+	exp := expect.NewT(ft, 50*time.Millisecond, echo)
+	exp.SendLine("one")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		exp.MustExpect(`three`)
+	}()
+	<-done
+
+	qt.Assert(t, qt.Equals(ft.fatal, strings.Join([]string{
+		"want `three`: expect: timeout",
+		"unmatched input (8 bytes):",
+		`    "> one\n"`,
+		`    "> "`,
+		"",
+	}, "\n")))
+}
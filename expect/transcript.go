@@ -0,0 +1,150 @@
+package expect
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The directions of a transcript entry.
+const (
+	dirRecv = "recv" // Received from the SUT.
+	dirSend = "send" // Sent to the SUT.
+)
+
+// logEntry writes to e.Log, if set, one line of the transcript:
+//
+//	2006-01-02T15:04:05.000000Z recv "hello\r\n"
+func (e *Expect) logEntry(dir string, data []byte) {
+	if e.Log == nil || len(data) == 0 {
+		return
+	}
+	if e.LogStripANSI {
+		data = StripANSI(data)
+	}
+	line := fmt.Sprintf("%s %s %s\n",
+		time.Now().UTC().Format("2006-01-02T15:04:05.000000Z"), dir,
+		strconv.Quote(string(data)))
+	e.logMu.Lock()
+	defer e.logMu.Unlock()
+	io.WriteString(e.Log, line)
+}
+
+// TLog returns a writer for [Expect.Log] that logs each line of the transcript
+// with t.Log, so that it is shown when the test fails (or with go test -v).
+// Lines written after the end of the test are dropped.
+func TLog(t testing.TB) io.Writer {
+	tl := &tLog{t: t}
+	t.Cleanup(func() {
+		tl.mu.Lock()
+		tl.done = true
+		tl.mu.Unlock()
+	})
+	return tl
+}
+
+type tLog struct {
+	t    testing.TB
+	mu   sync.Mutex
+	done bool
+}
+
+func (tl *tLog) Write(p []byte) (int, error) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	if !tl.done {
+		tl.t.Log(strings.TrimSuffix(string(p), "\n"))
+	}
+	return len(p), nil
+}
+
+// Replay returns an Expect connected to a fake SUT that replays transcript,
+// as written to [Expect.Log] (without LogStripANSI): the fake SUT writes the
+// received chunks, with the same boundaries, and checks that the test sends
+// the same bytes as in the transcript, in the same order. If the test sends
+// something else, Expect returns an error describing the difference. The
+// timing of the transcript is not replayed, so that the replay is
+// deterministic.
+func Replay(transcript io.Reader) (*Expect, error) {
+	type entry struct {
+		dir  string
+		data []byte
+	}
+	var entries []entry
+	scanner := bufio.NewScanner(transcript)
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		tokens := strings.SplitN(scanner.Text(), " ", 3)
+		if len(tokens) != 3 || (tokens[1] != dirRecv && tokens[1] != dirSend) {
+			return nil, fmt.Errorf("replay: line %d: want: <time> recv|send <quoted data>", n)
+		}
+		data, err := strconv.Unquote(tokens[2])
+		if err != nil {
+			return nil, fmt.Errorf("replay: line %d: %s", n, err)
+		}
+		entries = append(entries, entry{dir: tokens[1], data: []byte(data)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("replay: %s", err)
+	}
+
+	stdinRd, stdout, exp := New(TimeoutDef, MatchMaxDef)
+	go func() {
+		for i, ent := range entries {
+			if ent.dir == dirRecv {
+				if _, err := stdout.Write(ent.data); err != nil {
+					stdinRd.CloseWithError(err)
+					return
+				}
+				continue
+			}
+			have := make([]byte, len(ent.data))
+			n, err := io.ReadFull(stdinRd, have)
+			if err != nil || string(have) != string(ent.data) {
+				err = fmt.Errorf("replay: entry %d: have sent %q; want %q",
+					i+1, have[:n], ent.data)
+				stdinRd.CloseWithError(err)
+				stdout.CloseWithError(err)
+				return
+			}
+		}
+		stdinRd.Close()
+		stdout.Close()
+	}()
+	return exp, nil
+}
+
+var ansiRe = regexp.MustCompile(
+	"\x1b\\[[0-?]*[ -/]*[@-~]" + // CSI: cursor movement, colors, ...
+		"|\x1b\\][^\x07\x1b]*(\x07|\x1b\\\\)" + // OSC: window title, ...
+		"|\x1b[()][0-9A-Za-z]" + // Character set.
+		"|\x1b[=>78DEHMc]") // Keypad mode, save cursor, ...
+
+// StripANSI returns data without the ANSI escape sequences.
+func StripANSI(data []byte) []byte {
+	return ansiRe.ReplaceAll(data, nil)
+}
+
+// maxEscape is the maximum length of an escape sequence that
+// incompleteEscape holds back.
+const maxEscape = 32
+
+// incompleteEscape returns the index of the trailing escape sequence of data
+// if it might be incomplete (it has been split across reads), or len(data).
+func incompleteEscape(data []byte) int {
+	i := bytes.LastIndexByte(data, '\x1b')
+	if i < 0 || len(data)-i > maxEscape {
+		return len(data)
+	}
+	if loc := ansiRe.FindIndex(data[i:]); loc != nil && loc[0] == 0 {
+		return len(data)
+	}
+	return i
+}
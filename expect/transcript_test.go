@@ -0,0 +1,132 @@
+package expect_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-quicktest/qt"
+
+	"github.com/marco-m/otium/expect"
+)
+
+// greeter is a minimal SUT: it asks for a name and greets it.
+func greeter(stdin io.Reader, stdout io.Writer) error {
+	fmt.Fprint(stdout, "\x1b[1mname?\x1b[0m ")
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "hello %s", line)
+	return nil
+}
+
+// driveGreeter is the test of greeter, run both live and replayed.
+func driveGreeter(t *testing.T, exp *expect.Expect) {
+	_, err := exp.ExpectMatch(expect.Literal("name?"))
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.IsNil(exp.Send("joe\n")))
+	have, err := exp.Expect(`hello .*\n`)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(have, "hello joe\n"))
+	_, err = exp.Drain()
+	qt.Assert(t, qt.IsNil(err))
+}
+
+func TestTranscriptRecordAndReplay(t *testing.T) {
+	stdin, stdout, exp := expect.New(time.Second, expect.MatchMaxDef)
+	var log strings.Builder
+	exp.Log = &log
+	go func() {
+		greeter(stdin, stdout)
+		stdout.Close()
+	}()
+
+	driveGreeter(t, exp)
+
+	for _, line := range strings.Split(strings.TrimSpace(log.String()), "\n") {
+		qt.Assert(t, qt.Matches(line, `\d{4}-\d{2}-\d{2}T[\d:.]+Z (recv|send) ".*"`))
+	}
+	qt.Assert(t, qt.StringContains(log.String(), ` send "joe\n"`))
+
+	replay, err := expect.Replay(strings.NewReader(log.String()))
+	qt.Assert(t, qt.IsNil(err))
+
+	driveGreeter(t, replay)
+}
+
+func TestTranscriptReplayMismatch(t *testing.T) {
+	transcript := `2026-10-19T10:00:00.000000Z recv "name? "
+2026-10-19T10:00:00.100000Z send "joe\n"
+2026-10-19T10:00:00.200000Z recv "hello joe\n"
+`
+	exp, err := expect.Replay(strings.NewReader(transcript))
+	qt.Assert(t, qt.IsNil(err))
+
+	_, err = exp.Expect(`name\? `)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.IsNil(exp.Send("ann\n")))
+	_, err = exp.Expect(`hello`)
+
+	qt.Assert(t, qt.ErrorMatches(err,
+		`replay: entry 2: have sent "ann\\n"; want "joe\\n"`))
+}
+
+func TestTranscriptReplayInvalid(t *testing.T) {
+	_, err := expect.Replay(strings.NewReader("2026-10-19T10:00:00Z recv hello\n"))
+
+	qt.Assert(t, qt.ErrorMatches(err, "replay: line 1: invalid syntax"))
+}
+
+func TestTranscriptStripANSI(t *testing.T) {
+	stdin, stdout, exp := expect.New(time.Second, expect.MatchMaxDef)
+	var log strings.Builder
+	exp.Log = &log
+	exp.LogStripANSI = true
+	go func() {
+		greeter(stdin, stdout)
+		stdout.Close()
+	}()
+
+	driveGreeter(t, exp)
+
+	qt.Assert(t, qt.StringContains(log.String(), ` recv "name? "`))
+}
+
+func TestStripANSI(t *testing.T) {
+	type testCase struct {
+		name string
+		in   string
+		want string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		qt.Assert(t, qt.Equals(string(expect.StripANSI([]byte(tc.in))), tc.want))
+	}
+
+	testCases := []testCase{
+		{name: "no escapes", in: "(top)>> ", want: "(top)>> "},
+		{name: "colors", in: "\x1b[1;31merror\x1b[0m", want: "error"},
+		{name: "cursor", in: "\r\x1b[K(top)>> va\x1b[5D", want: "\r(top)>> va"},
+		{name: "title", in: "\x1b]0;otium\x07ok", want: "ok"},
+		{name: "keypad", in: "\x1b=ok\x1b>", want: "ok"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
+
+func TestTLog(t *testing.T) {
+	stdin, stdout, exp := expect.New(time.Second, expect.MatchMaxDef)
+	exp.Log = expect.TLog(t)
+	go func() {
+		greeter(stdin, stdout)
+		stdout.Close()
+	}()
+
+	driveGreeter(t, exp)
+}
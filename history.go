@@ -0,0 +1,67 @@
+package otium
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// historyMax is the maximum number of lines kept in the history file.
+const historyMax = 500
+
+// historyPath returns the path of the REPL history file of pcd.
+func (pcd *Procedure) historyPath() (string, error) {
+	dir, err := pcd.stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history"), nil
+}
+
+// loadHistory loads the REPL history from path into term. A non-existing
+// file is not an error.
+func loadHistory(term historian, path string) error {
+	fi, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer fi.Close()
+	_, err = term.ReadHistory(fi)
+	return err
+}
+
+// saveHistory writes to path the most recent historyMax lines of the REPL
+// history of term.
+func saveHistory(term historian, path string) error {
+	var buf bytes.Buffer
+	if _, err := term.WriteHistory(&buf); err != nil {
+		return err
+	}
+	lines := strings.SplitAfter(buf.String(), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > historyMax {
+		lines = lines[len(lines)-historyMax:]
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "")), 0o600)
+}
+
+// historyLine returns line, already split into args, as it must be appended
+// to the REPL history: the value of a secret variable is removed, so that it
+// is never written to the history file.
+func historyLine(bag Bag, args []string, line string) string {
+	if len(args) > 2 && args[0] == "set" && bag.bag[args[1]].Secret {
+		return "set " + args[1] + " "
+	}
+	return line
+}
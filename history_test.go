@@ -0,0 +1,69 @@
+package otium
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-quicktest/qt"
+)
+
+func TestHistorySaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fruits", "history")
+	term := newLinerPrompter()
+	// Restore terminal to previous mode, super important.
+	defer term.Close()
+
+	// A non-existing history is not an error.
+	qt.Assert(t, qt.IsNil(loadHistory(term, path)))
+
+	for i := 0; i < historyMax+10; i++ {
+		term.AppendHistory(fmt.Sprintf("cmd %d", i))
+	}
+	qt.Assert(t, qt.IsNil(saveHistory(term, path)))
+
+	buf, err := os.ReadFile(path)
+	qt.Assert(t, qt.IsNil(err))
+	lines := strings.Split(strings.TrimSuffix(string(buf), "\n"), "\n")
+	qt.Assert(t, qt.HasLen(lines, historyMax))
+	qt.Assert(t, qt.Equals(lines[0], "cmd 10"))
+	qt.Assert(t, qt.Equals(lines[historyMax-1], fmt.Sprintf("cmd %d", historyMax+9)))
+
+	term.ClearHistory()
+	qt.Assert(t, qt.IsNil(loadHistory(term, path)))
+	var have strings.Builder
+	n, err := term.WriteHistory(&have)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(n, historyMax))
+	qt.Assert(t, qt.Equals(have.String(), string(buf)))
+}
+
+func TestHistoryLine(t *testing.T) {
+	bag := NewBag()
+	bag.bag["token"] = Variable{Name: "token", Secret: true}
+	bag.bag["fruit"] = Variable{Name: "fruit"}
+
+	type testCase struct {
+		name string
+		line string
+		want string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		args := strings.Fields(tc.line)
+		qt.Assert(t, qt.Equals(historyLine(bag, args, tc.line), tc.want))
+	}
+
+	testCases := []testCase{
+		{name: "other command", line: "next", want: "next"},
+		{name: "set", line: "set fruit mango", want: "set fruit mango"},
+		{name: "set secret", line: "set token s3cr3t", want: "set token "},
+		{name: "edit secret", line: "edit token", want: "edit token"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
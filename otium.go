@@ -15,6 +15,19 @@ var (
 	//	},
 	ErrUnrecoverable = errors.New("(unrecoverable)")
 
+	// ErrQuit is returned by [Procedure.Execute] when the user quits before
+	// the end of an already started procedure.
+	ErrQuit = errors.New("(quit)")
+
+	// ErrStepFailed is returned by [Procedure.Execute] when the user quits
+	// after the last attempt of the next step failed.
+	ErrStepFailed = errors.New("(step failed)")
+
+	// ErrPromptAborted is returned by [Prompter] when the user aborts the
+	// prompt, for example with Ctrl-C. At the top level REPL, it behaves as
+	// the quit command.
+	ErrPromptAborted = errors.New("(prompt aborted)")
+
 	version = "something-went-wrong"
 )
 
@@ -23,6 +36,35 @@ var (
 	errBack = errors.New("go back (sentinel)")
 )
 
+// Exit codes returned by [ExitCode].
+const (
+	ExitCompleted     = 0
+	ExitError         = 1 // Any other error, for example an invalid procedure.
+	ExitAborted       = 3
+	ExitStepFailed    = 4
+	ExitUnrecoverable = 5
+)
+
+// ExitCode maps the error returned by [Procedure.Execute] to an exit code for
+// os.Exit, so that the caller of the program can distinguish a completed
+// procedure from one aborted by the user, one that failed at a step, or one
+// that failed with [ErrUnrecoverable]. See the examples for the suggested
+// usage.
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitCompleted
+	case errors.Is(err, ErrUnrecoverable):
+		return ExitUnrecoverable
+	case errors.Is(err, ErrStepFailed):
+		return ExitStepFailed
+	case errors.Is(err, ErrQuit):
+		return ExitAborted
+	default:
+		return ExitError
+	}
+}
+
 func init() {
 	info, ok := debug.ReadBuildInfo()
 	if !ok {
@@ -0,0 +1,82 @@
+package otiumtest
+
+import (
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+// updateEnv is the environment variable that, if true, makes Golden write the
+// golden files.
+const updateEnv = "OTIUM_UPDATE_GOLDEN"
+
+// updating returns true if Golden must write the golden files: if the
+// environment variable OTIUM_UPDATE_GOLDEN is true or if the test binary
+// defines a boolean flag -update (this package does not define it, to avoid
+// clashing with the flags of the user) and it is set.
+func updating() bool {
+	if ok, _ := strconv.ParseBool(os.Getenv(updateEnv)); ok {
+		return true
+	}
+	if f := flag.Lookup("update"); f != nil {
+		if getter, ok := f.Value.(flag.Getter); ok {
+			ok, _ := getter.Get().(bool)
+			return ok
+		}
+	}
+	return false
+}
+
+var (
+	timestampRe = regexp.MustCompile(
+		`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`)
+	durationRe = regexp.MustCompile(`\b(\d+h)?(\d+m)?\d+(\.\d+)?s\b`)
+)
+
+// Normalize replaces in s the timestamps with <time> and the durations with
+// <dur>, so that the output of a procedure can be compared across runs.
+func Normalize(s string) string {
+	s = timestampRe.ReplaceAllString(s, "<time>")
+	return durationRe.ReplaceAllString(s, "<dur>")
+}
+
+// Golden compares the normalized (see [Normalize]) have with the contents of
+// the golden file testdata/<name>.golden, failing the test if they differ.
+// If the environment variable OTIUM_UPDATE_GOLDEN is true, Golden writes the
+// golden file instead:
+//
+//	OTIUM_UPDATE_GOLDEN=1 go test -run TestFoo
+//
+// The same happens if the test package defines a boolean flag -update and
+// the test binary is invoked with it.
+func Golden(t testing.TB, name string, have string) {
+	t.Helper()
+
+	have = Normalize(have)
+	path := filepath.Join("testdata", name+".golden")
+	if updating() {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(have), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("golden file %s not found; to create it, run the test with %s=1", path, updateEnv)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have != string(want) {
+		t.Fatalf("output differs from golden file %s (to update it, run the test with %s=1)\nhave:\n%s\nwant:\n%s",
+			path, updateEnv, have, want)
+	}
+}
@@ -0,0 +1,195 @@
+// Package otiumtest runs an otium [otium.Procedure] against a script of
+// operator actions, without a terminal, so that a procedure can be tested as
+// any other Go code.
+//
+//	tr := otiumtest.Run(t, pcd, nil,
+//		otiumtest.Next(),
+//		otiumtest.Set("fruit", "banana"),
+//		otiumtest.Skip(3),
+//		otiumtest.Next(),
+//	)
+//	qt.Assert(t, qt.IsNil(tr.Err))
+//	qt.Assert(t, qt.DeepEquals(tr.Visited, []int{1, 2, 4}))
+//	otiumtest.Golden(t, "fruits", tr.Output)
+package otiumtest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/marco-m/otium"
+)
+
+// Action is what the operator enters at a prompt of the procedure. Each
+// action is consumed by exactly one prompt, be it the top level REPL or a
+// sub REPL (input, checklist, verify).
+type Action struct {
+	line string
+}
+
+// String returns the line entered by the action.
+func (act Action) String() string {
+	return act.line
+}
+
+// Line returns the action of entering line verbatim.
+func Line(line string) Action {
+	return Action{line: line}
+}
+
+// Next returns the action of running the next step.
+func Next() Action {
+	return Action{line: "next"}
+}
+
+// Set returns the action of setting variable key to val. It works both at
+// the top level REPL and when a step asks for key. Note that when a step asks
+// for a variable, the value cannot contain spaces.
+func Set(key, val string) Action {
+	return Action{line: "set " + key + " " + quote(val)}
+}
+
+// Skip returns the action of skipping steps (by default, the next one).
+func Skip(steps ...int) Action {
+	line := "skip"
+	for _, n := range steps {
+		line += " " + strconv.Itoa(n)
+	}
+	return Action{line: line}
+}
+
+// Done returns the action of ticking all the remaining items of a
+// checklist.
+func Done() Action {
+	return Action{line: "done all"}
+}
+
+// Back returns the action of going back from a sub REPL to the top level
+// REPL.
+func Back() Action {
+	return Action{line: "back"}
+}
+
+// Quit returns the action of quitting the procedure. If the procedure is in
+// progress, follow it with [Confirm].
+func Quit() Action {
+	return Action{line: "quit"}
+}
+
+// Confirm returns the action of answering yes to a confirmation.
+func Confirm() Action {
+	return Action{line: "y"}
+}
+
+// quote quotes val, if needed, for the top level REPL.
+func quote(val string) string {
+	if val != "" && !strings.ContainsAny(val, " \t\"'\\") {
+		return val
+	}
+	val = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(val)
+	return `"` + val + `"`
+}
+
+// Transcript is the outcome of [Run].
+type Transcript struct {
+	// Output is what the procedure wrote (both stdout and stderr), including
+	// the prompts and the echo of the actions, as in a terminal.
+	Output string
+	// Errors are the lines written by the procedure to stderr, for example
+	// the errors of the REPL commands.
+	Errors []string
+	// Err is the error returned by [otium.Procedure.Execute].
+	Err error
+	// Record is the run record of the procedure.
+	Record otium.RunRecord
+	// Visited are the numbers of the steps visited, in order.
+	Visited []int
+	// Vars are the contents of the bag at the end (secrets redacted).
+	Vars map[string]string
+	// Unused is the number of actions not consumed by the procedure.
+	Unused int
+}
+
+// Run executes pcd with the command-line flags args, replacing the operator
+// with actions. When the actions are exhausted, the procedure sees the end
+// of the input, as if the operator pressed Ctrl-D.
+//
+// Run sets fields Stdout, Stderr and Prompter of pcd and, if not already set,
+// field StateDir to a temporary directory, so that the tests do not pollute
+// the state of the user. Since the command-line is parsed with
+// flag.ExitOnError, invalid args terminate the test binary.
+func Run(t testing.TB, pcd *otium.Procedure, args []string, actions ...Action) Transcript {
+	t.Helper()
+
+	var out, errOut bytes.Buffer
+	script := &scriptPrompter{out: &out, actions: actions}
+	pcd.Stdout = &out
+	pcd.Stderr = io.MultiWriter(&out, &errOut)
+	pcd.Stdin = nil
+	pcd.Prompter = script
+	if pcd.StateDir == "" {
+		pcd.StateDir = t.TempDir()
+	}
+
+	err := pcd.Execute(append([]string{"otiumtest"}, args...))
+
+	tr := Transcript{
+		Output: out.String(),
+		Err:    err,
+		Record: pcd.Record(),
+		Unused: len(script.actions),
+	}
+	tr.Vars = tr.Record.Vars
+	for _, line := range strings.Split(errOut.String(), "\n") {
+		if line != "" {
+			tr.Errors = append(tr.Errors, line)
+		}
+	}
+	var visited []otium.StepRecord
+	for _, step := range tr.Record.Steps {
+		if !step.Start.IsZero() {
+			visited = append(visited, step)
+		}
+	}
+	sort.SliceStable(visited, func(i, j int) bool {
+		return visited[i].Start.Before(visited[j].Start)
+	})
+	for _, step := range visited {
+		tr.Visited = append(tr.Visited, step.N)
+	}
+	return tr
+}
+
+// scriptPrompter is an [otium.Prompter] that answers each prompt with the
+// next action, echoing it to out.
+type scriptPrompter struct {
+	out     io.Writer
+	actions []Action
+}
+
+func (sp *scriptPrompter) Prompt(prompt string) (string, error) {
+	fmt.Fprint(sp.out, prompt)
+	if len(sp.actions) == 0 {
+		fmt.Fprintln(sp.out)
+		return "", io.EOF
+	}
+	line := sp.actions[0].line
+	sp.actions = sp.actions[1:]
+	fmt.Fprintln(sp.out, line)
+	return line, nil
+}
+
+func (sp *scriptPrompter) PromptWithSuggestion(prompt string, text string, pos int) (string, error) {
+	return sp.Prompt(prompt)
+}
+
+func (sp *scriptPrompter) SetCompleter(f otium.Completer) {}
+
+func (sp *scriptPrompter) AppendHistory(item string) {}
+
+func (sp *scriptPrompter) Close() error { return nil }
@@ -0,0 +1,110 @@
+package otiumtest_test
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-quicktest/qt"
+
+	"github.com/marco-m/otium"
+	"github.com/marco-m/otium/otiumtest"
+)
+
+func newFruitsProcedure() *otium.Procedure {
+	pcd := otium.NewProcedure(otium.ProcedureOpts{
+		Name:  "fruits",
+		Title: "Fruits",
+		Desc:  "Breakfast with fruits.",
+	})
+	pcd.AddStep(&otium.Step{Title: "Go to the market"})
+	pcd.AddStep(&otium.Step{
+		Title: "Buy fruit",
+		Desc:  "Buy a {{.fruit}}.",
+		Vars:  []otium.Variable{{Name: "fruit", Desc: "Fruit to buy"}},
+	})
+	pcd.AddStep(&otium.Step{
+		Title: "Wash fruit",
+		Run:   func(bag otium.Bag, uctx any) error { return nil },
+	})
+	pcd.AddStep(&otium.Step{
+		Title: "Eat fruit",
+		Run:   func(bag otium.Bag, uctx any) error { return nil },
+	})
+	return pcd
+}
+
+func TestRunComplete(t *testing.T) {
+	tr := otiumtest.Run(t, newFruitsProcedure(), nil,
+		otiumtest.Next(),
+		otiumtest.Next(),
+		otiumtest.Set("fruit", "banana"),
+		otiumtest.Skip(3),
+		otiumtest.Next(),
+	)
+
+	qt.Assert(t, qt.IsNil(tr.Err))
+	qt.Assert(t, qt.DeepEquals(tr.Visited, []int{1, 2, 4}))
+	qt.Assert(t, qt.DeepEquals(tr.Vars, map[string]string{"fruit": "banana"}))
+	qt.Assert(t, qt.Equals(tr.Record.Steps[2].Status, otium.StatusSkipped))
+	qt.Assert(t, qt.Equals(tr.Unused, 0))
+	qt.Assert(t, qt.IsNil(tr.Errors))
+	otiumtest.Golden(t, "complete", tr.Output)
+}
+
+func TestRunActionsExhausted(t *testing.T) {
+	tr := otiumtest.Run(t, newFruitsProcedure(), []string{"--fruit", "mango"},
+		otiumtest.Next(),
+	)
+
+	qt.Assert(t, qt.IsTrue(errors.Is(tr.Err, otium.ErrQuit)))
+	qt.Assert(t, qt.DeepEquals(tr.Visited, []int{1}))
+	qt.Assert(t, qt.DeepEquals(tr.Vars, map[string]string{"fruit": "mango"}))
+}
+
+func TestRunErrorsAndUnusedActions(t *testing.T) {
+	tr := otiumtest.Run(t, newFruitsProcedure(), nil,
+		otiumtest.Line("banana"),
+		otiumtest.Set("fruit", "kiwi fruit"),
+		otiumtest.Next(),
+		otiumtest.Quit(),
+		otiumtest.Confirm(),
+		otiumtest.Next(),
+	)
+
+	qt.Assert(t, qt.IsTrue(errors.Is(tr.Err, otium.ErrQuit)))
+	qt.Assert(t, qt.HasLen(tr.Errors, 1))
+	qt.Assert(t, qt.StringContains(tr.Errors[0], `unexpected argument banana`))
+	qt.Assert(t, qt.DeepEquals(tr.Vars, map[string]string{"fruit": "kiwi fruit"}))
+	qt.Assert(t, qt.Equals(tr.Unused, 1))
+}
+
+func TestNormalize(t *testing.T) {
+	have := otiumtest.Normalize(
+		"Started: 2026-10-19T10:11:12+02:00\nElapsed: 1m30s\n| 2 | 0s |")
+
+	qt.Assert(t, qt.Equals(have,
+		"Started: <time>\nElapsed: <dur>\n| 2 | <dur> |"))
+}
+
+func TestGoldenUpdateFromEnv(t *testing.T) {
+	// The package must not register -update, to not clash with the flags of
+	// the user.
+	qt.Assert(t, qt.IsNil(flag.Lookup("update")))
+	wd, err := os.Getwd()
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.IsNil(os.Chdir(t.TempDir())))
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	t.Setenv("OTIUM_UPDATE_GOLDEN", "1")
+	otiumtest.Golden(t, "hello", "hello at 2023-08-01T10:00:00Z\n")
+
+	have, err := os.ReadFile(filepath.Join("testdata", "hello.golden"))
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(string(have), "hello at <time>\n"))
+
+	t.Setenv("OTIUM_UPDATE_GOLDEN", "")
+	otiumtest.Golden(t, "hello", "hello at 2024-01-01T00:00:00Z\n")
+}
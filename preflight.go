@@ -0,0 +1,239 @@
+package otium
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Check is a named pre-flight check, run before the first step and with the
+// preflight command. See [ProcedureOpts.Checks] and the constructors
+// [CheckBinary], [CheckEnv], [CheckFile], [CheckPort] and [CheckFunc].
+type Check struct {
+	// Name is shown in the table of the results.
+	Name string
+	// Warning, if true, means that the failure of the check is reported but
+	// does not block the procedure.
+	Warning bool
+	// Run performs the check. On success, it can return a detail to show in
+	// the table of the results, for example the version found.
+	Run func(ctx context.Context) (string, error)
+}
+
+// AsWarning returns a copy of check that does not block the procedure if it
+// fails.
+func (check Check) AsWarning() Check {
+	check.Warning = true
+	return check
+}
+
+// checkTimeout is the maximum duration of a Check.
+const checkTimeout = 10 * time.Second
+
+// CheckBinary returns a Check that program name is on the PATH and, if
+// minVersion is not empty, that its version is at least minVersion. The
+// version is the first dotted number (for example 1.2.3) in the output of
+// the program invoked with versionArgs (by default, --version).
+func CheckBinary(name, minVersion string, versionArgs ...string) Check {
+	title := name + " on PATH"
+	if minVersion != "" {
+		title = fmt.Sprintf("%s >= %s on PATH", name, minVersion)
+	}
+	if len(versionArgs) == 0 {
+		versionArgs = []string{"--version"}
+	}
+	return Check{
+		Name: title,
+		Run: func(ctx context.Context) (string, error) {
+			path, err := exec.LookPath(name)
+			if err != nil {
+				return "", fmt.Errorf("not found")
+			}
+			if minVersion == "" {
+				return path, nil
+			}
+			out, err := exec.CommandContext(ctx, path, versionArgs...).CombinedOutput()
+			if err != nil {
+				return "", fmt.Errorf("%s %s: %s", name, strings.Join(versionArgs, " "), err)
+			}
+			version := versionRe.FindString(string(out))
+			if version == "" {
+				return "", fmt.Errorf("no version in the output of %s %s",
+					name, strings.Join(versionArgs, " "))
+			}
+			if compareVersions(version, minVersion) < 0 {
+				return "", fmt.Errorf("have version %s; want >= %s", version, minVersion)
+			}
+			return "version " + version, nil
+		},
+	}
+}
+
+var versionRe = regexp.MustCompile(`\d+(\.\d+)+`)
+
+// compareVersions compares the dotted versions a and b, returning -1, 0 or
+// +1. A missing component counts as 0, so that 1.2 == 1.2.0.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return +1
+		}
+	}
+	return 0
+}
+
+// CheckEnv returns a Check that the environment variable key is set and not
+// empty.
+func CheckEnv(key string) Check {
+	return Check{
+		Name: "env " + key,
+		Run: func(ctx context.Context) (string, error) {
+			if os.Getenv(key) == "" {
+				return "", fmt.Errorf("not set")
+			}
+			return "set", nil
+		},
+	}
+}
+
+// CheckFile returns a Check that the file at path exists and is readable.
+func CheckFile(path string) Check {
+	return Check{
+		Name: "file " + path,
+		Run: func(ctx context.Context) (string, error) {
+			fi, err := os.Open(path)
+			if err != nil {
+				return "", pathErr(err)
+			}
+			defer fi.Close()
+			if _, err := fi.Read(make([]byte, 1)); err != nil && err != io.EOF {
+				return "", pathErr(err)
+			}
+			return "readable", nil
+		},
+	}
+}
+
+// pathErr returns the cause of err if it is a *fs.PathError, since the path
+// is already in the name of the check.
+func pathErr(err error) error {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		return pe.Err
+	}
+	return err
+}
+
+// CheckPort returns a Check that a TCP server is listening on port of
+// localhost.
+func CheckPort(port int) Check {
+	addr := net.JoinHostPort("localhost", strconv.Itoa(port))
+	return Check{
+		Name: "port " + addr,
+		Run: func(ctx context.Context) (string, error) {
+			var dialer net.Dialer
+			conn, err := dialer.DialContext(ctx, "tcp", addr)
+			if err != nil {
+				return "", fmt.Errorf("not reachable")
+			}
+			conn.Close()
+			return "reachable", nil
+		},
+	}
+}
+
+// CheckFunc returns a Check called name, that calls fn.
+func CheckFunc(name string, fn func(ctx context.Context) error) Check {
+	return Check{
+		Name: name,
+		Run: func(ctx context.Context) (string, error) {
+			return "", fn(ctx)
+		},
+	}
+}
+
+// checkResult is the outcome of a Check.
+type checkResult struct {
+	check  Check
+	detail string
+	err    error
+}
+
+// validate checks that check is valid. Meant to be called by
+// Procedure.Execute.
+func (check Check) validate(n int) error {
+	var errs []error
+	if strings.TrimSpace(check.Name) == "" {
+		errs = append(errs, fmt.Errorf("check (%d) has empty Name", n))
+	}
+	if check.Run == nil {
+		errs = append(errs, fmt.Errorf("check (%d) has nil Run", n))
+	}
+	return errors.Join(errs...)
+}
+
+// runChecks runs checks, in order, and writes the table of the results to
+// out. It returns an error if at least one blocking check failed.
+func runChecks(ctx context.Context, checks []Check, out io.Writer) error {
+	results := make([]checkResult, 0, len(checks))
+	for _, check := range checks {
+		ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+		detail, err := check.Run(ctx)
+		cancel()
+		results = append(results, checkResult{check: check, detail: detail, err: err})
+	}
+	writeChecks(out, results)
+
+	var failed []string
+	for _, res := range results {
+		if res.err != nil && !res.check.Warning {
+			failed = append(failed, strconv.Quote(res.check.Name))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("preflight: %d blocking checks failed: %s",
+			len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// writeChecks writes the table of the results of the checks to wr.
+func writeChecks(wr io.Writer, results []checkResult) {
+	fmt.Fprintf(wr, "\n## Pre-flight checks\n\n")
+	fmt.Fprintf(wr, "| %-6s | %-40s | %s |\n", "Result", "Check", "Detail")
+	fmt.Fprintf(wr, "|--------|%s|--------|\n", strings.Repeat("-", 42))
+	for _, res := range results {
+		result, detail := "pass", res.detail
+		if detail == "" {
+			detail = "-"
+		}
+		if res.err != nil {
+			result, detail = "FAIL", res.err.Error()
+			if res.check.Warning {
+				result = "warn"
+			}
+		}
+		fmt.Fprintf(wr, "| %-6s | %-40s | %s |\n", result, res.check.Name, detail)
+	}
+	fmt.Fprintln(wr)
+}
@@ -0,0 +1,169 @@
+package otium
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-quicktest/qt"
+)
+
+func TestCompareVersions(t *testing.T) {
+	type testCase struct {
+		a, b string
+		want int
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		qt.Assert(t, qt.Equals(compareVersions(tc.a, tc.b), tc.want))
+	}
+
+	testCases := []testCase{
+		{a: "1.2.3", b: "1.2.3", want: 0},
+		{a: "1.2", b: "1.2.0", want: 0},
+		{a: "1.10", b: "1.9", want: +1},
+		{a: "1.9.9", b: "2.0", want: -1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.a+" vs "+tc.b, func(t *testing.T) { run(t, tc) })
+	}
+}
+
+func TestChecks(t *testing.T) {
+	type testCase struct {
+		name       string
+		check      Check
+		wantDetail string
+		wantErr    string
+	}
+
+	dir := t.TempDir()
+	readable := filepath.Join(dir, "readable")
+	qt.Assert(t, qt.IsNil(os.WriteFile(readable, []byte("hello"), 0o600)))
+	t.Setenv("OTIUM_TEST_SET", "yes")
+	t.Setenv("OTIUM_TEST_EMPTY", "")
+	ln, err := net.Listen("tcp", "localhost:0")
+	qt.Assert(t, qt.IsNil(err))
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+	closed, err := net.Listen("tcp", "localhost:0")
+	qt.Assert(t, qt.IsNil(err))
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+	// The go command is on the PATH, since it is running the tests.
+	goVersion := []string{"version"}
+
+	run := func(t *testing.T, tc testCase) {
+		detail, err := tc.check.Run(context.Background())
+
+		if tc.wantErr != "" {
+			qt.Assert(t, qt.ErrorMatches(err, tc.wantErr))
+			return
+		}
+		qt.Assert(t, qt.IsNil(err))
+		qt.Assert(t, qt.Matches(detail, tc.wantDetail))
+	}
+
+	testCases := []testCase{
+		{
+			name:       "binary found",
+			check:      CheckBinary("go", ""),
+			wantDetail: `.*go.*`,
+		},
+		{
+			name:    "binary not found",
+			check:   CheckBinary("otium-no-such-binary", ""),
+			wantErr: `not found`,
+		},
+		{
+			name:       "binary version ok",
+			check:      CheckBinary("go", "1.0", goVersion...),
+			wantDetail: `version 1\.[0-9.]+`,
+		},
+		{
+			name:    "binary version too old",
+			check:   CheckBinary("go", "999.0", goVersion...),
+			wantErr: `have version 1\.[0-9.]+; want >= 999.0`,
+		},
+		{
+			name:       "env set",
+			check:      CheckEnv("OTIUM_TEST_SET"),
+			wantDetail: `set`,
+		},
+		{
+			name:    "env empty",
+			check:   CheckEnv("OTIUM_TEST_EMPTY"),
+			wantErr: `not set`,
+		},
+		{
+			name:       "file readable",
+			check:      CheckFile(readable),
+			wantDetail: `readable`,
+		},
+		{
+			name:    "file missing",
+			check:   CheckFile(filepath.Join(dir, "missing")),
+			wantErr: `no such file or directory`,
+		},
+		{
+			name:    "file is a directory",
+			check:   CheckFile(dir),
+			wantErr: `is a directory`,
+		},
+		{
+			name:       "port reachable",
+			check:      CheckPort(port),
+			wantDetail: `reachable`,
+		},
+		{
+			name:    "port not reachable",
+			check:   CheckPort(closedPort),
+			wantErr: `not reachable`,
+		},
+		{
+			name: "func",
+			check: CheckFunc("logged in", func(ctx context.Context) error {
+				return errors.New("token expired")
+			}),
+			wantErr: `token expired`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
+
+func TestRunChecks(t *testing.T) {
+	pass := CheckFunc("pass", func(ctx context.Context) error { return nil })
+	fail := CheckFunc("fail", func(ctx context.Context) error { return errors.New("boom") })
+	var out bytes.Buffer
+
+	err := runChecks(context.Background(),
+		[]Check{pass, fail.AsWarning(), fail, CheckEnv("OTIUM_TEST_NOT_SET")}, &out)
+
+	qt.Assert(t, qt.ErrorMatches(err,
+		`preflight: 2 blocking checks failed: "fail", "env OTIUM_TEST_NOT_SET"`))
+	qt.Assert(t, qt.Equals(out.String(), `
+## Pre-flight checks
+
+| Result | Check                                    | Detail |
+|--------|------------------------------------------|--------|
+| pass   | pass                                     | - |
+| warn   | fail                                     | boom |
+| FAIL   | fail                                     | boom |
+| FAIL   | env OTIUM_TEST_NOT_SET                   | not set |
+
+`))
+}
+
+func TestCheckValidate(t *testing.T) {
+	err := Check{}.validate(2)
+
+	qt.Assert(t, qt.ErrorMatches(err, "check \\(2\\) has empty Name\ncheck \\(2\\) has nil Run"))
+}
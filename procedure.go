@@ -1,6 +1,7 @@
 package otium
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
@@ -8,10 +9,10 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/google/shlex"
-	"github.com/peterh/liner"
 )
 
 // Procedure is made of a sequence of [Step]. Create it with [NewProcedure],
@@ -23,9 +24,24 @@ type Procedure struct {
 	stepIdx int // Index into the step to execute.
 	bag     Bag
 	uctx    any // The optional user context.
+	dryRun  bool
+	// rehearse[i] is true if step i must be rehearsed (if automated).
+	rehearse []bool
+	// profile is the active profile, if any (flag --profile).
+	profile profile
+	// replay, if not nil, answers the prompts with the lines of --replay.
+	replay *replayPrompter
+	run    RunRecord
+	// pending is the record of the manual step that the human is performing,
+	// whose end is recorded at the next top level command. See closeStep.
+	pending *StepRecord
+	now     func() time.Time // The clock; replaced in tests.
+	history []RunRecord      // Run records of the previous executions.
 	parser  *kong.Kong
+	out     io.Writer
+	errOut  io.Writer
 	// Warning: term will be initialized by Execute(), not by NewProcedure().
-	term *liner.State
+	term Prompter
 }
 
 // ProcedureOpts is used by [NewProcedure] to create a Procedure.
@@ -43,25 +59,102 @@ type ProcedureOpts struct {
 	// context. Such user context will then be passed as parameter uctx to each call
 	// of Step.Run(bag Bag, uctx any).
 	PreFlight func() (any, error)
+	// Checks are optional pre-flight checks, run in order after the
+	// command-line has been parsed and before PreFlight. The results are
+	// shown in a table; if a check not marked as Warning fails, the procedure
+	// does not start. The user can run them again with command preflight.
+	Checks []Check
+	// Commands are optional procedure-specific commands, available at the top
+	// level REPL together with the otium commands.
+	Commands []Command
+	// Stdout is where the procedure writes its output; by default os.Stdout.
+	// Note that the output of Step.Run is up to the user code.
+	Stdout io.Writer
+	// Stderr is where the procedure writes the errors of the REPL and of the
+	// command-line parsing; by default os.Stderr.
+	Stderr io.Writer
+	// Stdin, if set and if Prompter is not set, is where the procedure reads
+	// the user input, with a Prompter created by [NewLinePrompter].
+	Stdin io.Reader
+	// Prompter, if set, is used to read the user input. The caller is in
+	// charge of closing it. If neither Prompter nor Stdin are set, the
+	// procedure reads from the terminal, with line editing, completion and
+	// history.
+	Prompter Prompter
+	// StateDir is the directory where the procedure keeps its state (run
+	// records, REPL history); by default $XDG_STATE_HOME/otium/<Name>.
+	StateDir string
+	// ProfilesFile is the file containing the profiles selected with flag
+	// --profile; by default $XDG_CONFIG_HOME/otium/<Name>/profiles.json.
+	ProfilesFile string
+}
+
+// Command is a procedure-specific command of the top level REPL. See
+// [ProcedureOpts.Commands].
+type Command struct {
+	// Name is what the user enters to invoke the command.
+	Name string
+	// Help is the description of the command shown by the help command.
+	Help string
+	// Args are the names of the arguments of the command, shown in the help.
+	// If set, the user must enter exactly len(Args) arguments; if not set,
+	// the user can enter any number of arguments.
+	Args []string
+	// Run is called with the writer of the procedure (see
+	// [ProcedureOpts.Stdout]), the arguments entered by the user, the bag and
+	// the user context (see [ProcedureOpts.PreFlight]).
+	Run func(out io.Writer, args []string, bag Bag, uctx any) error
 }
 
 // NewProcedure creates a Procedure.
 func NewProcedure(opts ProcedureOpts) *Procedure {
-	return &Procedure{
+	pcd := &Procedure{
 		ProcedureOpts: opts,
 		bag:           NewBag(),
-		parser: kong.Must(&topcli{},
-			kong.Name(""),
-			kong.Exit(func(int) {}),
-			kong.ConfigureHelp(kong.HelpOptions{
-				// Must be disabled because it doesn't make sense in a REPL.
-				NoAppSummary:   true,
-				WrapUpperBound: 80,
-			}),
+		now:           time.Now,
+	}
+	pcd.initIO()
+	return pcd
+}
+
+// initIO sets the output writers from fields Stdout and Stderr, defaulting to
+// os.Stdout and os.Stderr. It is called also by Execute, to take into account
+// changes to the fields done after NewProcedure.
+func (pcd *Procedure) initIO() {
+	pcd.out, pcd.errOut = pcd.Stdout, pcd.Stderr
+	if pcd.out == nil {
+		pcd.out = os.Stdout
+	}
+	if pcd.errOut == nil {
+		pcd.errOut = os.Stderr
+	}
+}
+
+// newParser returns the parser of the top level REPL, made of the otium
+// commands plus the procedure-specific commands.
+func newParser(commands []Command, stdout, stderr io.Writer) (*kong.Kong, error) {
+	options := []kong.Option{
+		kong.Name(""),
+		kong.Writers(stdout, stderr),
+		kong.Exit(func(int) {}),
+		kong.ConfigureHelp(kong.HelpOptions{
 			// Must be disabled because it doesn't make sense in a REPL.
-			kong.NoDefaultHelp(),
-		),
+			NoAppSummary:   true,
+			WrapUpperBound: 80,
+		}),
+		// Must be disabled because it doesn't make sense in a REPL.
+		kong.NoDefaultHelp(),
 	}
+	for _, cmd := range commands {
+		help := cmd.Help
+		if len(cmd.Args) > 0 {
+			help = fmt.Sprintf("%s Usage: %s <%s>", help, cmd.Name,
+				strings.Join(cmd.Args, "> <"))
+		}
+		options = append(options,
+			kong.DynamicCommand(cmd.Name, help, "", &customCmd{cmd: cmd}))
+	}
+	return kong.New(&topcli{}, options...)
 }
 
 // AddStep adds a [Step] to [Procedure].
@@ -74,6 +167,7 @@ func (pcd *Procedure) AddStep(step *Step) {
 // If it returns an error, the user program should print it and exit with a
 // non-zero status code. See the examples for the suggested usage.
 func (pcd *Procedure) Execute(args []string) error {
+	pcd.initIO()
 	var errs []error
 	errs = append(errs, pcd.validate())
 	for i, step := range pcd.steps {
@@ -82,6 +176,10 @@ func (pcd *Procedure) Execute(args []string) error {
 	if err := errors.Join(errs...); err != nil {
 		return err
 	}
+	var err error
+	if pcd.parser, err = newParser(pcd.Commands, pcd.out, pcd.errOut); err != nil {
+		return fmt.Errorf("commands: %s", err)
+	}
 
 	// Fill the bag with all the Vars from all the steps.
 	// A duplicate variable is considered an error.
@@ -102,9 +200,18 @@ func (pcd *Procedure) Execute(args []string) error {
 	if err := errors.Join(errs...); err != nil {
 		return err
 	}
+	pcd.run = newRunRecord(pcd.Name, pcd.steps)
+	pcd.run.Title = pcd.Title
+	pcd.run.Mode = ModeLive
+	if dir, err := pcd.runLogDir(); err == nil {
+		// Run records are only used for estimates: if some of them cannot be
+		// read, we keep going with the others.
+		pcd.history, _ = loadRunRecords(dir)
+	}
 
 	// Setup command-line parsing.
 	cliFlags := flag.NewFlagSet(args[0], flag.ExitOnError)
+	cliFlags.SetOutput(pcd.errOut)
 
 	// Add the Vars in the bag as CLI flags.
 	for name, variable := range pcd.bag.bag {
@@ -121,6 +228,40 @@ func (pcd *Procedure) Execute(args []string) error {
 
 	var docOnly bool
 	cliFlags.BoolVar(&docOnly, "doc-only", false, "Print documentation only instead of running")
+	cliFlags.BoolVar(&pcd.dryRun, "dry-run", false,
+		"Run the procedure without running the automated steps, showing what they would do")
+	pcd.rehearse = make([]bool, len(pcd.steps))
+	var rehearsal bool
+	cliFlags.BoolVar(&rehearsal, "rehearsal", false,
+		"Rehearse all the automated steps: run Step.FakeRun or let the human perform them")
+	cliFlags.Func("rehearse",
+		"Rehearse the automated steps in comma-separated `list` (for example 2,4)",
+		func(val string) error {
+			idxs, err := pcd.parseRehearse(val)
+			if err != nil {
+				return err
+			}
+			for _, i := range idxs {
+				pcd.rehearse[i] = true
+			}
+			return nil
+		})
+	var noHistory bool
+	cliFlags.BoolVar(&noHistory, "no-history", false, "Do not load nor save the REPL history")
+	var reportPath string
+	cliFlags.StringVar(&reportPath, "report", "",
+		"At the end, write the summary report to `file` (JSON if extension is .json, markdown otherwise)")
+	var profileName string
+	cliFlags.StringVar(&profileName, "profile", "",
+		"Preset the variables with the values of profile `name` (see also --profiles)")
+	cliFlags.StringVar(&pcd.ProfilesFile, "profiles", pcd.ProfilesFile,
+		"The `file` containing the profiles (default $XDG_CONFIG_HOME/otium/<name>/profiles.json)")
+	var recordPath string
+	cliFlags.StringVar(&recordPath, "record", "",
+		"Record to `file` the commands and the inputs entered (secrets excluded), for --replay")
+	var replayPath string
+	cliFlags.StringVar(&replayPath, "replay", "",
+		"Replay the commands and the inputs recorded in `file` by --record, pausing where they do not apply")
 
 	// Parse the command-line.
 	cliFlags.Usage = func() {
@@ -137,6 +278,44 @@ func (pcd *Procedure) Execute(args []string) error {
 		return err
 	}
 
+	if rehearsal {
+		for i := range pcd.rehearse {
+			pcd.rehearse[i] = true
+		}
+	}
+	if docOnly && pcd.dryRun {
+		return errors.New("flags --doc-only and --dry-run are mutually exclusive")
+	}
+	if pcd.dryRun {
+		pcd.run.Mode = ModeDryRun
+	} else if pcd.rehearsing() {
+		pcd.run.Mode = ModeRehearsal
+	}
+	if profileName != "" {
+		path, err := pcd.profilesPath()
+		if err != nil {
+			return fmt.Errorf("profile: %s", err)
+		}
+		if pcd.profile, err = loadProfile(path, profileName); err != nil {
+			return err
+		}
+		if err := pcd.applyProfile(pcd.profile); err != nil {
+			return err
+		}
+	}
+	var recording []inputRecord
+	if replayPath != "" && !docOnly {
+		if recording, err = loadRecording(replayPath); err != nil {
+			return err
+		}
+	}
+
+	if !docOnly && len(pcd.Checks) > 0 {
+		if err := runChecks(context.Background(), pcd.Checks, pcd.out); err != nil {
+			return err
+		}
+	}
+
 	if !docOnly && pcd.PreFlight != nil {
 		var err error
 		pcd.uctx, err = pcd.PreFlight()
@@ -145,37 +324,71 @@ func (pcd *Procedure) Execute(args []string) error {
 		}
 	}
 
-	// We cannot initialize liner before (say, in NewProcedure), because
-	// NewLiner changes the terminal line discipline, so we must do this
-	// _after_ having parsed the command-line.
-	pcd.term = liner.NewLiner()
-	// Restore terminal to previous mode, super important.
-	defer pcd.term.Close()
-	pcd.term.SetCtrlCAborts(true)
+	switch {
+	case pcd.Prompter != nil:
+		pcd.term = pcd.Prompter
+	case pcd.Stdin != nil:
+		pcd.term = NewLinePrompter(pcd.Stdin, pcd.out)
+	default:
+		// We cannot initialize liner before (say, in NewProcedure), because
+		// NewLiner changes the terminal line discipline, so we must do this
+		// _after_ having parsed the command-line.
+		pcd.term = newLinerPrompter()
+		// Restore terminal to previous mode, super important.
+		defer pcd.term.Close()
+	}
 
-	//
-	// Configure completer, part 1.
-	// TODO think how to make it know deeper about the possible completions...
-	//
-	pcd.term.SetTabCompletionStyle(liner.TabPrints)
-	var commands []string
-	for _, node := range pcd.parser.Model.Children {
-		commands = append(commands, node.Name)
-	}
-	topCompleter := func(line string) []string {
-		completions := make([]string, 0, len(commands))
-		line = strings.ToLower(line)
-		for _, cmd := range commands {
-			if strings.HasPrefix(cmd, line) {
-				completions = append(completions, cmd)
+	if hist, ok := pcd.term.(historian); ok && !docOnly && !noHistory {
+		// The history is a convenience: if it cannot be loaded or saved, we
+		// only warn.
+		path, err := pcd.historyPath()
+		if err != nil {
+			fmt.Fprintf(pcd.out, "(top) Warning: no REPL history: %s\n", err)
+		} else {
+			if err := loadHistory(hist, path); err != nil {
+				fmt.Fprintf(pcd.out, "(top) Warning: cannot load the REPL history: %s\n", err)
 			}
+			defer func() {
+				if err := saveHistory(hist, path); err != nil {
+					fmt.Fprintf(pcd.out, "(top) Warning: cannot save the REPL history: %s\n", err)
+				}
+			}()
 		}
-		//fmt.Printf("completer: %q, completions: %q\n", line, completions)
-		return completions
 	}
 
-	fmt.Printf("# %s\n\n", pcd.Title)
-	fmt.Printf("%s\n", pcd.Desc)
+	// Wrap the Prompter only now, since the history needs the original one.
+	if replayPath != "" && !docOnly {
+		pcd.replay = newReplayPrompter(pcd.term, pcd.out, recording)
+		pcd.term = pcd.replay
+	}
+	if recordPath != "" && !docOnly {
+		recorder, err := newRecordingPrompter(pcd.term, pcd.out, pcd.bag, recordPath)
+		if err != nil {
+			return err
+		}
+		defer recorder.Close()
+		pcd.term = recorder
+	}
+
+	//
+	// Configure completer, part 1.
+	//
+	topCompleter := makeTopCompleter(pcd)
+
+	fmt.Fprintf(pcd.out, "# %s\n\n", pcd.Title)
+	if pcd.dryRun {
+		fmt.Fprintf(pcd.out, "%s\n\n", dryRunBanner)
+	}
+	if pcd.rehearsing() {
+		fmt.Fprintf(pcd.out, "%s\n\n", rehearsalBanner)
+	}
+	if pcd.replay != nil {
+		fmt.Fprintf(pcd.out, "**REPLAY** of %s: %d recorded inputs.\n\n", replayPath, len(recording))
+	}
+	if pcd.profile.name != "" {
+		fmt.Fprintf(pcd.out, "Profile: %s (%s)\n\n", pcd.profile.name, pcd.profile.path)
+	}
+	fmt.Fprintf(pcd.out, "%s\n", pcd.Desc)
 	printToc(pcd)
 
 	if docOnly {
@@ -191,10 +404,17 @@ func (pcd *Procedure) Execute(args []string) error {
 	// Main loop.
 	//
 	var kongCtx *kong.Context
+	pcd.run.Start = pcd.now()
 	for {
+		for pcd.stepIdx < len(pcd.steps) &&
+			pcd.run.Steps[pcd.stepIdx].Status == StatusSkipped {
+			fmt.Fprintf(pcd.out, "\n(top) Skipping step: %d. %s\n",
+				pcd.stepIdx+1, pcd.steps[pcd.stepIdx].Title)
+			pcd.stepIdx++
+		}
 		if pcd.stepIdx == len(pcd.steps) {
-			fmt.Printf("\n(top) Procedure terminated successfully\n")
-			return nil
+			fmt.Fprintf(pcd.out, "\n(top) Procedure terminated successfully\n")
+			return pcd.finish(reportPath)
 		}
 
 		// We set the completer on each loop because the sub repl in bag.Get
@@ -202,16 +422,21 @@ func (pcd *Procedure) Execute(args []string) error {
 		pcd.term.SetCompleter(topCompleter)
 
 		next := pcd.steps[pcd.stepIdx]
-		fmt.Printf("\n(top) Next step: %d. %s %s\n",
-			pcd.stepIdx+1, next.Icon(), next.Title)
-		fmt.Printf("(top) Enter a command or '?' for help\n")
+		fmt.Fprintf(pcd.out, "\n(top) Next step: %d. %s %s\n",
+			pcd.stepIdx+1, pcd.icon(pcd.stepIdx), next.Title)
+		fmt.Fprintf(pcd.out, "(top) Enter a command or '?' for help\n")
 		var line string
-		line, err := pcd.term.Prompt("(top)>> ")
-		// TODO if we receive EOF, we should return no error if the procedure
-		//  has not started yet, and an error if the procedure has already
-		//  started
+		line, err := pcd.term.Prompt(topPrompt)
+		pcd.closeStep()
+		if errors.Is(err, ErrPromptAborted) || errors.Is(err, io.EOF) {
+			// Ctrl-C and Ctrl-D behave as the quit command.
+			if err = cmdQuit(pcd); err == nil {
+				continue
+			}
+			return pcd.quit(reportPath)
+		}
 		if err != nil {
-			return err
+			return fmt.Errorf("reading the command: %w", err)
 		}
 
 		//
@@ -228,25 +453,94 @@ func (pcd *Procedure) Execute(args []string) error {
 			pcd.parser.Errorf("%s", err)
 			continue
 		}
-		pcd.term.AppendHistory(line)
+		pcd.term.AppendHistory(historyLine(pcd.bag, args, line))
 
 		//
 		// Execute user command.
 		//
+		before := pcd.run.Steps[pcd.stepIdx]
 		err = kongCtx.Run(&bind{pcd: pcd})
-		if errors.Is(err, io.EOF) || errors.Is(err, ErrUnrecoverable) {
-			return err
+		if errors.Is(err, ErrUnrecoverable) {
+			return errors.Join(err, pcd.finish(reportPath))
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, ErrQuit) {
+			return pcd.quit(reportPath)
 		}
 		if errors.Is(err, errBack) {
 			continue
 		}
 		if err != nil {
+			if pcd.replay != nil && failedNow(before, pcd.run.Steps[pcd.stepIdx]) {
+				pcd.replay.pause()
+			}
 			pcd.parser.Errorf("%s", err)
 			continue
 		}
 	}
 }
 
+// failedNow returns true if the command that changed the step record from
+// before to after made the step fail, either for the first time or at a new
+// attempt. A command that did not run the step, such as show or set, leaves
+// a previous failure as it is.
+func failedNow(before, after StepRecord) bool {
+	return after.Status == StatusFailed &&
+		(before.Status != StatusFailed || after.Attempts != before.Attempts)
+}
+
+// closeStep records the end of the pending manual step, if any. A manual step
+// without Checklist nor Verify is performed by the human after having read
+// it, so it ends only when the human enters the next top level command (or
+// when the procedure finishes).
+func (pcd *Procedure) closeStep() {
+	if pcd.pending == nil {
+		return
+	}
+	pcd.pending.End = pcd.now()
+	pcd.pending = nil
+}
+
+// started returns true if the user has already visited at least one step.
+func (pcd *Procedure) started() bool {
+	if pcd.stepIdx > 0 {
+		return true
+	}
+	for _, rec := range pcd.run.Steps {
+		if !rec.Start.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+// quit ends the REPL before the end of the procedure. If the procedure has
+// not started yet, there is nothing to record and quit returns nil.
+// Otherwise, it writes the summary report and returns ErrQuit or, if the
+// last attempt of the next step failed, ErrStepFailed.
+func (pcd *Procedure) quit(reportPath string) error {
+	if !pcd.started() {
+		return nil
+	}
+	fmt.Fprintf(pcd.out, "\n(top) Procedure quit at step %d of %d\n",
+		pcd.stepIdx+1, len(pcd.steps))
+	var err error = ErrQuit
+	if pcd.run.Steps[pcd.stepIdx].Status == StatusFailed {
+		err = fmt.Errorf("step %d: %w", pcd.stepIdx+1, ErrStepFailed)
+	}
+	return errors.Join(err, pcd.finish(reportPath))
+}
+
+// Record returns the run record of the last execution of the procedure, with
+// the current variables of the bag (secrets redacted). It is meant to inspect
+// the procedure after [Procedure.Execute] has returned, for example in tests
+// (see package otiumtest).
+func (pcd *Procedure) Record() RunRecord {
+	run := pcd.run
+	run.Steps = append([]StepRecord(nil), pcd.run.Steps...)
+	run.Vars = collectVars(pcd.bag)
+	return run
+}
+
 func (pcd *Procedure) Put(key, val string) {
 	pcd.bag.Put(key, val)
 }
@@ -268,19 +562,100 @@ func (pcd *Procedure) validate() error {
 		errs = append(errs,
 			errors.New("procedure has zero steps; want at least one"))
 	}
+	builtin, err := newParser(nil, io.Discard, io.Discard)
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool)
+	for _, node := range builtin.Model.Children {
+		names[node.Name] = true
+	}
+	for i, check := range pcd.Checks {
+		errs = append(errs, check.validate(i+1))
+	}
+	for i, cmd := range pcd.Commands {
+		switch {
+		case cmd.Name == "":
+			errs = append(errs, fmt.Errorf("command (%d) has empty Name", i+1))
+		case names[cmd.Name]:
+			errs = append(errs, fmt.Errorf("command %q: duplicate name", cmd.Name))
+		}
+		if cmd.Run == nil {
+			errs = append(errs, fmt.Errorf("command (%d) has nil Run", i+1))
+		}
+		names[cmd.Name] = true
+	}
 
 	return errors.Join(errs...)
 }
 
 // Table of contents
 func printToc(pcd *Procedure) {
-	fmt.Printf("\n## Table of contents\n\n")
+	fmt.Fprintf(pcd.out, "\n## Table of contents\n\n")
 	for i, step := range pcd.steps {
 		var next string
 		if i == pcd.stepIdx {
 			next = "next->"
 		}
-		fmt.Printf("%6s %2d. %s %s\n", next, i+1, step.Icon(), step.Title)
+		var skipped string
+		if pcd.run.Steps != nil && pcd.run.Steps[i].Status == StatusSkipped {
+			skipped = " (skipped)"
+		}
+		var rehearsed string
+		if pcd.rehearsed(i) {
+			rehearsed = " (REHEARSAL)"
+		}
+		fmt.Fprintf(pcd.out, "%6s %2d. %s %s%s%s%s\n", next, i+1, pcd.icon(i),
+			step.Title, rehearsed, skipped, estimate(step, pcd.history))
+	}
+	if eta, unknown := pcd.eta(); eta > 0 {
+		fmt.Fprintf(pcd.out, "\nETA: %s", formatDuration(eta))
+		if unknown > 0 {
+			fmt.Fprintf(pcd.out, " (plus %d steps without estimate)", unknown)
+		}
+		fmt.Fprintln(pcd.out)
+	}
+	fmt.Fprintln(pcd.out)
+}
+
+// Thresholds to flag a step as consistently slower than expected.
+const (
+	slowFactor  = 2
+	slowMinRuns = 3
+)
+
+// estimate returns the expected duration of step, if any, flagged if the
+// median of the durations recorded in history is much longer than expected.
+func estimate(step *Step, history []RunRecord) string {
+	if step.ExpectedDuration == 0 {
+		return ""
+	}
+	text := fmt.Sprintf(" (~%s)", formatDuration(step.ExpectedDuration))
+	durations := stepDurations(history, step.Title)
+	if len(durations) < slowMinRuns {
+		return text
+	}
+	if med := median(durations); med > slowFactor*step.ExpectedDuration {
+		text += fmt.Sprintf(" ⚠️ median %s over %d runs",
+			formatDuration(med), len(durations))
+	}
+	return text
+}
+
+// eta returns the sum of the expected durations of the remaining steps and
+// the number of remaining steps without an expected duration.
+func (pcd *Procedure) eta() (time.Duration, int) {
+	var eta time.Duration
+	var unknown int
+	for i := pcd.stepIdx; i < len(pcd.steps); i++ {
+		if pcd.run.Steps != nil && pcd.run.Steps[i].Status == StatusSkipped {
+			continue
+		}
+		if pcd.steps[i].ExpectedDuration == 0 {
+			unknown++
+			continue
+		}
+		eta += pcd.steps[i].ExpectedDuration
 	}
-	fmt.Println()
+	return eta, unknown
 }
@@ -1,9 +1,15 @@
 package otium_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -11,10 +17,24 @@ import (
 
 	"github.com/marco-m/otium"
 	"github.com/marco-m/otium/expect"
+	"github.com/marco-m/otium/otiumtest"
 )
 
 var osArgs = []string{"exe.name"}
 
+func TestMain(m *testing.M) {
+	// Do not pollute the state directory of the user running the tests.
+	dir, err := os.MkdirTemp("", "otium-test-")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	os.Setenv("XDG_STATE_HOME", dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 func TestProcedure_ExecuteWithZeroStepsFails(t *testing.T) {
 	pcd := otium.NewProcedure(otium.ProcedureOpts{
 		Title: "Simple title",
@@ -39,6 +59,37 @@ func TestProcedure_ExecuteStepWithMissingFieldsFails(t *testing.T) {
 	qt.Assert(t, qt.ErrorMatches(err, `step \(1\) has empty Title`))
 }
 
+func TestProcedure_ExecuteStepWithRunAndVerifyFails(t *testing.T) {
+	pcd := otium.NewProcedure(otium.ProcedureOpts{
+		Title: "Simple title",
+		Desc:  `Simple description`,
+	})
+	pcd.AddStep(&otium.Step{
+		Title: "Step A",
+		Run:   func(bag otium.Bag, uctx any) error { return nil },
+		Verify: func(ctx context.Context, bag otium.Bag, uctx any) error {
+			return nil
+		},
+	})
+
+	err := pcd.Execute(osArgs)
+
+	qt.Assert(t, qt.ErrorMatches(err,
+		`step \(1\) has both Run and Verify; want at most one`))
+}
+
+func TestProcedure_ExecuteStepWithDryRunWithoutRunFails(t *testing.T) {
+	pcd := otium.NewProcedure(otium.ProcedureOpts{Title: "Simple title"})
+	pcd.AddStep(&otium.Step{
+		Title:  "Step A",
+		DryRun: func(bag otium.Bag, uctx any) (string, error) { return "", nil },
+	})
+
+	err := pcd.Execute(osArgs)
+
+	qt.Assert(t, qt.ErrorMatches(err, `step \(1\) has DryRun but not Run`))
+}
+
 func TestProcedure_ExecuteDuplicateVarsInSameStepFail(t *testing.T) {
 	pcd := otium.NewProcedure(otium.ProcedureOpts{
 		Title: "Simple title",
@@ -109,8 +160,8 @@ next->  1. 🤠 step 1
 (top) Next step: 1. 🤠 step 1
 (top) Enter a command or '?' for help
 (top)>> `
-	// Flag (?s) means that . matches also \n
-	have, err := exp.Expect(`(?s).*\(top\)>> `)
+	m, err := exp.ExpectMatch(expect.Literal("(top)>> "))
+	have := m.Upto()
 	qt.Check(t, qt.IsNil(err))
 	qt.Assert(t, qt.Equals(have, want1))
 
@@ -160,8 +211,8 @@ next->  1. 🤖 step 1
 (top) Next step: 1. 🤖 step 1
 (top) Enter a command or '?' for help
 (top)>> `
-	// Flag (?s) means that . matches also \n
-	have, err := exp.Expect(`(?s).*\(top\)>> `)
+	m, err := exp.ExpectMatch(expect.Literal("(top)>> "))
+	have := m.Upto()
 	qt.Check(t, qt.IsNil(err))
 	qt.Assert(t, qt.Equals(have, want1))
 
@@ -208,7 +259,7 @@ func TestProcedure_ExecuteOneStepWithRunFailure(t *testing.T) {
 		asyncErr <- err
 	}()
 
-	_, err := exp.Expect(`\(top\)>> `)
+	_, err := exp.ExpectMatch(expect.Literal("(top)>> "))
 	qt.Assert(t, qt.IsNil(err))
 
 	err = exp.Send("next\n")
@@ -375,8 +426,8 @@ next->  1. 🤖 Modify user context
 (top) Next step: 1. 🤖 Modify user context
 (top) Enter a command or '?' for help
 (top)>> `
-	// Flag (?s) means that . matches also \n
-	have1, err := exp.Expect(`(?s).*\(top\)>> `)
+	m1, err := exp.ExpectMatch(expect.Literal("(top)>> "))
+	have1 := m1.Upto()
 	qt.Check(t, qt.IsNil(err))
 	qt.Assert(t, qt.Equals(have1, want1))
 
@@ -384,14 +435,14 @@ next->  1. 🤖 Modify user context
 	qt.Assert(t, qt.IsNil(err))
 
 	want2 := `
-## 1. 🤖 Modify user context
+## 1. 🤖 Modify user context (elapsed 0s)
 
 
 (top) Next step: 2. 🤖 Read modified user context
 (top) Enter a command or '?' for help
 (top)>> `
-	// Flag (?s) means that . matches also \n
-	have2, err := exp.Expect(`(?s).*\(top\)>> `)
+	m2, err := exp.ExpectMatch(expect.Literal("(top)>> "))
+	have2 := m2.Upto()
 	qt.Check(t, qt.IsNil(err))
 	qt.Assert(t, qt.Equals(have2, want2))
 
@@ -404,3 +455,788 @@ next->  1. 🤖 Modify user context
 	err = <-asyncErr
 	qt.Assert(t, qt.IsNil(err))
 }
+
+func TestProcedure_ExecuteSkipAndWriteReport(t *testing.T) {
+	exp, cleanup := expect.NewFilePipe(100*time.Millisecond, expect.MatchMaxDef)
+	defer cleanup()
+	report := filepath.Join(t.TempDir(), "report.json")
+
+	sut := otium.NewProcedure(otium.ProcedureOpts{Title: "Simple title"})
+	sut.AddStep(&otium.Step{
+		Title: "step 1",
+		Vars:  []otium.Variable{{Name: "token", Secret: true}},
+	})
+	sut.AddStep(&otium.Step{Title: "step 2"})
+
+	asyncErr := make(chan error)
+	go func() {
+		err := sut.Execute([]string{"exe.name", "--token=s3cr3t",
+			"--report=" + report})
+		os.Stdout.Close()
+		asyncErr <- err
+	}()
+
+	_, err := exp.ExpectMatch(expect.Literal("(top)>> "))
+	qt.Assert(t, qt.IsNil(err))
+	err = exp.Send("next\n")
+	qt.Assert(t, qt.IsNil(err))
+
+	_, err = exp.ExpectMatch(expect.Literal("(top)>> "))
+	qt.Assert(t, qt.IsNil(err))
+	err = exp.Send("skip\n")
+	qt.Assert(t, qt.IsNil(err))
+
+	have, err := exp.Expect(`(?s).*terminated successfully\n`)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(have, `
+(top) Skipping step: 2. step 2
+
+(top) Procedure terminated successfully
+`))
+	_, err = exp.Drain()
+	qt.Assert(t, qt.IsNil(err))
+
+	err = <-asyncErr
+	qt.Assert(t, qt.IsNil(err))
+
+	buf, err := os.ReadFile(report)
+	qt.Assert(t, qt.IsNil(err))
+	var run otium.RunRecord
+	err = json.Unmarshal(buf, &run)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(run.Steps[0].Status, otium.StatusDone))
+	qt.Assert(t, qt.Equals(run.Steps[1].Status, otium.StatusSkipped))
+	qt.Assert(t, qt.DeepEquals(run.Vars, map[string]string{"token": "<redacted>"}))
+}
+
+func TestProcedure_ExecuteCommandsWithInvalidNamesFail(t *testing.T) {
+	run := func(out io.Writer, args []string, bag otium.Bag, uctx any) error { return nil }
+	pcd := otium.NewProcedure(otium.ProcedureOpts{
+		Title: "Simple title",
+		Commands: []otium.Command{
+			{Name: "next", Run: run},
+			{Name: "", Run: run},
+			{Name: "whoami"},
+		},
+	})
+	pcd.AddStep(&otium.Step{Title: "Step A"})
+
+	err := pcd.Execute(osArgs)
+
+	qt.Assert(t, qt.ErrorMatches(err, `command "next": duplicate name
+command \(2\) has empty Name
+command \(3\) has nil Run`))
+}
+
+func TestProcedure_ExecuteCustomCommand(t *testing.T) {
+	exp, cleanup := expect.NewFilePipe(100*time.Millisecond, expect.MatchMaxDef)
+	defer cleanup()
+
+	sut := otium.NewProcedure(otium.ProcedureOpts{
+		Title: "Simple title",
+		Commands: []otium.Command{{
+			Name: "greet",
+			Help: "Greet somebody.",
+			Args: []string{"name"},
+			Run: func(out io.Writer, args []string, bag otium.Bag, uctx any) error {
+				fruit, err := bag.Get("fruit")
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(out, "hello %s, have a %s\n", args[0], fruit)
+				return nil
+			},
+		}},
+	})
+	sut.AddStep(&otium.Step{
+		Title: "step 1",
+		Vars:  []otium.Variable{{Name: "fruit"}},
+	})
+
+	asyncErr := make(chan error)
+	go func() {
+		err := sut.Execute([]string{"exe.name", "--fruit=mango"})
+		os.Stdout.Close()
+		asyncErr <- err
+	}()
+
+	_, err := exp.ExpectMatch(expect.Literal("(top)>> "))
+	qt.Assert(t, qt.IsNil(err))
+
+	err = exp.Send("greet joe\n")
+	qt.Assert(t, qt.IsNil(err))
+	have, err := exp.Expect(`hello .*\n`)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(have, "hello joe, have a mango\n"))
+
+	err = exp.Send("greet ann\n")
+	qt.Assert(t, qt.IsNil(err))
+	have, err = exp.Expect(`hello .*\n`)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(have, "hello ann, have a mango\n"))
+
+	// Quitting before the procedure has started is not an error.
+	err = exp.Send("quit\n")
+	qt.Assert(t, qt.IsNil(err))
+	_, err = exp.Drain()
+	qt.Assert(t, qt.IsNil(err))
+
+	err = <-asyncErr
+	qt.Assert(t, qt.IsNil(err))
+}
+
+func TestProcedure_ExecuteQuitInProgress(t *testing.T) {
+	type testCase struct {
+		name     string
+		run      func(bag otium.Bag, uctx any) error
+		wantNext int
+		wantErr  error
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		exp, cleanup := expect.NewFilePipe(100*time.Millisecond, expect.MatchMaxDef)
+		defer cleanup()
+
+		sut := otium.NewProcedure(otium.ProcedureOpts{Title: "Simple title"})
+		sut.AddStep(&otium.Step{Title: "step 1"})
+		sut.AddStep(&otium.Step{Title: "step 2", Run: tc.run})
+		sut.AddStep(&otium.Step{Title: "step 3"})
+
+		asyncErr := make(chan error)
+		go func() {
+			err := sut.Execute(osArgs)
+			os.Stdout.Close()
+			asyncErr <- err
+		}()
+
+		for _, cmd := range []string{"next", "next"} {
+			_, err := exp.ExpectMatch(expect.Literal("(top)>> "))
+			qt.Assert(t, qt.IsNil(err))
+			err = exp.Send(cmd + "\n")
+			qt.Assert(t, qt.IsNil(err))
+		}
+
+		_, err := exp.ExpectMatch(expect.Literal("(top)>> "))
+		qt.Assert(t, qt.IsNil(err))
+		err = exp.Send("quit\n")
+		qt.Assert(t, qt.IsNil(err))
+		have, err := exp.Expect(`\(top\) The procedure .*\n.*\[y/N\] `)
+		qt.Assert(t, qt.IsNil(err))
+		qt.Assert(t, qt.Equals(have, fmt.Sprintf(`(top) The procedure is in progress (next step: %d of 3)
+(top) Quit anyway? [y/N] `, tc.wantNext)))
+		// Change of mind.
+		err = exp.Send("n\n")
+		qt.Assert(t, qt.IsNil(err))
+
+		_, err = exp.ExpectMatch(expect.Literal("(top)>> "))
+		qt.Assert(t, qt.IsNil(err))
+		err = exp.Send("quit\n")
+		qt.Assert(t, qt.IsNil(err))
+		_, err = exp.ExpectMatch(expect.Literal("[y/N] "))
+		qt.Assert(t, qt.IsNil(err))
+		err = exp.Send("y\n")
+		qt.Assert(t, qt.IsNil(err))
+
+		have, err = exp.Expect(`(?s).*## Summary`)
+		qt.Assert(t, qt.IsNil(err))
+		qt.Assert(t, qt.StringContains(have, "(top) Procedure quit at step"))
+		_, err = exp.Drain()
+		qt.Assert(t, qt.IsNil(err))
+
+		err = <-asyncErr
+		qt.Assert(t, qt.ErrorIs(err, tc.wantErr))
+	}
+
+	testCases := []testCase{
+		{
+			name:     "user aborted",
+			wantNext: 3,
+			wantErr:  otium.ErrQuit,
+		},
+		{
+			name: "step failed",
+			run: func(bag otium.Bag, uctx any) error {
+				return fmt.Errorf("connection refused")
+			},
+			wantNext: 2,
+			wantErr:  otium.ErrStepFailed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	qt.Assert(t, qt.Equals(otium.ExitCode(nil), otium.ExitCompleted))
+	qt.Assert(t, qt.Equals(otium.ExitCode(fmt.Errorf("invalid")), otium.ExitError))
+	qt.Assert(t, qt.Equals(otium.ExitCode(otium.ErrQuit), otium.ExitAborted))
+	qt.Assert(t, qt.Equals(otium.ExitCode(
+		fmt.Errorf("step 2: %w", otium.ErrStepFailed)), otium.ExitStepFailed))
+	qt.Assert(t, qt.Equals(otium.ExitCode(
+		fmt.Errorf("flatlined %w", otium.ErrUnrecoverable)), otium.ExitUnrecoverable))
+}
+
+func TestProcedure_ExecuteWithInjectedIOConcurrently(t *testing.T) {
+	run := func(t *testing.T, fruit string) {
+		stdin, stdout, exp := expect.New(time.Second, expect.MatchMaxDef)
+
+		sut := otium.NewProcedure(otium.ProcedureOpts{
+			Title:  "Simple title",
+			Stdin:  stdin,
+			Stdout: stdout,
+			Stderr: io.Discard,
+		})
+		sut.AddStep(&otium.Step{
+			Title: "step 1",
+			Desc:  "Eat a {{.fruit}}",
+			Vars:  []otium.Variable{{Name: "fruit", Desc: "Fruit"}},
+		})
+
+		asyncErr := make(chan error)
+		go func() {
+			err := sut.Execute(osArgs)
+			stdout.Close()
+			asyncErr <- err
+		}()
+
+		_, err := exp.ExpectMatch(expect.Literal("(top)>> "))
+		qt.Assert(t, qt.IsNil(err))
+		err = exp.Send("next\n")
+		qt.Assert(t, qt.IsNil(err))
+
+		_, err = exp.ExpectMatch(expect.Literal("(input)>> "))
+		qt.Assert(t, qt.IsNil(err))
+		err = exp.Send("set fruit " + fruit + "\n")
+		qt.Assert(t, qt.IsNil(err))
+
+		have, err := exp.Expect(`.*terminated successfully`)
+		qt.Assert(t, qt.IsNil(err))
+		qt.Assert(t, qt.Equals(have, "(top) Procedure terminated successfully"))
+
+		have, err = exp.Expect(`fruit: .*\n`)
+		qt.Assert(t, qt.IsNil(err))
+		qt.Assert(t, qt.Equals(have, "fruit: "+fruit+"\n"))
+		_, err = exp.Drain()
+		qt.Assert(t, qt.IsNil(err))
+
+		err = <-asyncErr
+		qt.Assert(t, qt.IsNil(err))
+	}
+
+	for _, fruit := range []string{"mango", "banana", "kiwi"} {
+		fruit := fruit
+		t.Run(fruit, func(t *testing.T) {
+			t.Parallel()
+			run(t, fruit)
+		})
+	}
+}
+
+func TestProcedure_ExecuteDryRun(t *testing.T) {
+	var preFlight, runs int
+	pcd := otium.NewProcedure(otium.ProcedureOpts{
+		Title: "Simple title",
+		PreFlight: func() (any, error) {
+			preFlight++
+			return nil, nil
+		},
+	})
+	pcd.AddStep(&otium.Step{
+		Title: "Step A",
+		Desc:  "Delete {{.node}}",
+		Vars:  []otium.Variable{{Name: "node"}},
+	})
+	pcd.AddStep(&otium.Step{
+		Title: "Step B",
+		Run: func(bag otium.Bag, uctx any) error {
+			runs++
+			return nil
+		},
+		DryRun: func(bag otium.Bag, uctx any) (string, error) {
+			node, err := bag.Get("node")
+			return "delete " + node, err
+		},
+	})
+
+	tr := otiumtest.Run(t, pcd, []string{"--dry-run", "--node", "node-1"},
+		otiumtest.Next(),
+		otiumtest.Next(),
+	)
+
+	qt.Assert(t, qt.IsNil(tr.Err))
+	qt.Assert(t, qt.Equals(preFlight, 1))
+	qt.Assert(t, qt.Equals(runs, 0))
+	qt.Assert(t, qt.DeepEquals(tr.Visited, []int{1, 2}))
+	// The banner is shown both at the start and in the summary.
+	qt.Assert(t, qt.Equals(strings.Count(tr.Output, "**DRY RUN**"), 2))
+	qt.Assert(t, qt.StringContains(tr.Output, " (dry run)\n"))
+	qt.Assert(t, qt.StringContains(tr.Output, "Delete node-1"))
+	qt.Assert(t, qt.StringContains(tr.Output, "(dry-run) Would run:\n    delete node-1\n"))
+	// A dry run does not save its run record.
+	_, err := os.Stat(filepath.Join(pcd.StateDir, "runs"))
+	qt.Assert(t, qt.IsTrue(os.IsNotExist(err)))
+}
+
+func TestProcedure_ExecuteDryRunAndDocOnlyFail(t *testing.T) {
+	pcd := otium.NewProcedure(otium.ProcedureOpts{Title: "Simple title"})
+	pcd.AddStep(&otium.Step{Title: "Step A"})
+
+	err := pcd.Execute([]string{"exe.name", "--dry-run", "--doc-only"})
+
+	qt.Assert(t, qt.ErrorMatches(err,
+		"flags --doc-only and --dry-run are mutually exclusive"))
+}
+
+func TestProcedure_ExecuteRehearsal(t *testing.T) {
+	type testCase struct {
+		name       string
+		args       []string
+		wantRuns   []string
+		wantOut    []string
+		wantStatus []otium.StepStatus
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		var runs []string
+		pcd := otium.NewProcedure(otium.ProcedureOpts{Title: "Simple title"})
+		pcd.AddStep(&otium.Step{Title: "Step A"})
+		pcd.AddStep(&otium.Step{
+			Title: "Step B",
+			Run: func(bag otium.Bag, uctx any) error {
+				runs = append(runs, "B")
+				return nil
+			},
+		})
+		pcd.AddStep(&otium.Step{
+			Title: "Step C",
+			Run: func(bag otium.Bag, uctx any) error {
+				runs = append(runs, "C")
+				return nil
+			},
+			FakeRun: func(bag otium.Bag, uctx any) error {
+				runs = append(runs, "fake C")
+				return nil
+			},
+		})
+
+		tr := otiumtest.Run(t, pcd, tc.args,
+			otiumtest.Next(), otiumtest.Next(), otiumtest.Next())
+
+		qt.Assert(t, qt.IsNil(tr.Err))
+		qt.Assert(t, qt.DeepEquals(runs, tc.wantRuns))
+		for _, want := range tc.wantOut {
+			qt.Assert(t, qt.StringContains(tr.Output, want))
+		}
+		var status []otium.StepStatus
+		for _, rec := range pcd.Record().Steps {
+			status = append(status, rec.Status)
+		}
+		qt.Assert(t, qt.DeepEquals(status, tc.wantStatus))
+	}
+
+	testCases := []testCase{
+		{
+			name:     "live run",
+			wantRuns: []string{"B", "C"},
+			wantStatus: []otium.StepStatus{
+				otium.StatusDone, otium.StatusDone, otium.StatusDone},
+		},
+		{
+			name:     "rehearse all",
+			args:     []string{"--rehearsal"},
+			wantRuns: []string{"fake C"},
+			wantOut: []string{
+				"**REHEARSAL**",
+				" 1. 🤠 Step A\n",
+				"        2. 🎭 Step B (REHEARSAL)\n",
+				"        3. 🎭 Step C (REHEARSAL)\n",
+				"## 2. 🎭 Step B (REHEARSAL)",
+				"(rehearsal) Not run: perform the step manually",
+				"## Summary: Simple title\n\n**REHEARSAL**",
+			},
+			// Without FakeRun, B is performed by the human, if at all.
+			wantStatus: []otium.StepStatus{
+				otium.StatusDone, otium.StatusSkipped, otium.StatusDone},
+		},
+		{
+			name:     "rehearse one",
+			args:     []string{"--rehearse", "3"},
+			wantRuns: []string{"B", "fake C"},
+			wantOut: []string{
+				"        2. 🤖 Step B\n",
+				"        3. 🎭 Step C (REHEARSAL)\n",
+				"## Summary: Simple title\n\n**REHEARSAL**",
+			},
+			wantStatus: []otium.StepStatus{
+				otium.StatusDone, otium.StatusDone, otium.StatusDone},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
+
+func TestProcedure_ExecuteRecordThenReplay(t *testing.T) {
+	newPcd := func(fruits *[]string) *otium.Procedure {
+		pcd := otium.NewProcedure(otium.ProcedureOpts{Title: "Simple title"})
+		pcd.AddStep(&otium.Step{
+			Title: "Step A",
+			Vars:  []otium.Variable{{Name: "fruit"}},
+		})
+		pcd.AddStep(&otium.Step{
+			Title: "Step B",
+			Run: func(bag otium.Bag, uctx any) error {
+				fruit, _ := bag.Get("fruit")
+				*fruits = append(*fruits, fruit)
+				return nil
+			},
+		})
+		return pcd
+	}
+	path := filepath.Join(t.TempDir(), "recording")
+
+	var recorded []string
+	tr := otiumtest.Run(t, newPcd(&recorded), []string{"--record", path},
+		otiumtest.Next(), otiumtest.Set("fruit", "banana"), otiumtest.Next())
+	qt.Assert(t, qt.IsNil(tr.Err))
+	qt.Assert(t, qt.DeepEquals(recorded, []string{"banana"}))
+
+	var replayed []string
+	tr = otiumtest.Run(t, newPcd(&replayed), []string{"--replay", path})
+	qt.Assert(t, qt.IsNil(tr.Err))
+	qt.Assert(t, qt.DeepEquals(replayed, []string{"banana"}))
+	qt.Assert(t, qt.DeepEquals(tr.Visited, []int{1, 2}))
+	qt.Assert(t, qt.StringContains(tr.Output, "**REPLAY** of "))
+	qt.Assert(t, qt.StringContains(tr.Output, "(input)>> set fruit banana\n"))
+}
+
+func TestProcedure_ExecuteReplayPausesOnlyWhenAStepFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recording")
+	err := os.WriteFile(path, []byte(`{"prompt": "(top)>> ", "line": "next"}
+{"prompt": "(top)>> ", "line": "show 9"}
+{"prompt": "(top)>> ", "line": "next"}
+`), 0o600)
+	qt.Assert(t, qt.IsNil(err))
+	attempts := 0
+	pcd := otium.NewProcedure(otium.ProcedureOpts{Title: "Simple title"})
+	pcd.AddStep(&otium.Step{
+		Title: "Step A",
+		Run: func(bag otium.Bag, uctx any) error {
+			attempts++
+			if attempts == 1 {
+				return errors.New("connection refused")
+			}
+			return nil
+		},
+	})
+
+	// The replay pauses after the failure of step 1, and the operator enters
+	// a command. The error of the replayed "show 9" does not pause it again,
+	// since step 1 was already failed.
+	tr := otiumtest.Run(t, pcd, []string{"--replay", path},
+		otiumtest.Line("variables"))
+
+	qt.Assert(t, qt.IsNil(tr.Err))
+	qt.Assert(t, qt.Equals(tr.Unused, 0))
+	qt.Assert(t, qt.Equals(attempts, 2))
+	qt.Assert(t, qt.Equals(strings.Count(tr.Output, "(replay) Paused: a step failed"), 1))
+}
+
+func TestProcedure_ExecuteProfile(t *testing.T) {
+	type testCase struct {
+		name     string
+		args     []string
+		actions  []otiumtest.Action
+		wantVars map[string]string
+		wantErr  string
+	}
+
+	profiles := filepath.Join(t.TempDir(), "profiles.json")
+	err := os.WriteFile(profiles, []byte(`{
+    "dev":  {"region": "eu-west-1"},
+    "prod": {"region": "eu-central-1", "replicas": "3"},
+    "bad":  {"replicas": "many"}
+}`), 0o600)
+	qt.Assert(t, qt.IsNil(err))
+
+	run := func(t *testing.T, tc testCase) {
+		pcd := otium.NewProcedure(otium.ProcedureOpts{
+			Title:        "Simple title",
+			ProfilesFile: profiles,
+		})
+		pcd.AddStep(&otium.Step{
+			Title: "Step A",
+			Vars: []otium.Variable{
+				{Name: "region"},
+				{
+					Name: "replicas",
+					Fn: func(val string) error {
+						if val != "1" && val != "3" {
+							return fmt.Errorf("replicas: want 1 or 3; have %q", val)
+						}
+						return nil
+					},
+				},
+			},
+		})
+
+		tr := otiumtest.Run(t, pcd, tc.args, tc.actions...)
+
+		if tc.wantErr != "" {
+			qt.Assert(t, qt.ErrorMatches(tr.Err, tc.wantErr))
+			qt.Assert(t, qt.IsNil(tr.Visited))
+			return
+		}
+		qt.Assert(t, qt.IsNil(tr.Err))
+		qt.Assert(t, qt.DeepEquals(tr.Vars, tc.wantVars))
+		qt.Assert(t, qt.Equals(tr.Unused, 0))
+	}
+
+	testCases := []testCase{
+		{
+			name:     "profile sets all",
+			args:     []string{"--profile", "prod"},
+			actions:  []otiumtest.Action{otiumtest.Next()},
+			wantVars: map[string]string{"region": "eu-central-1", "replicas": "3"},
+		},
+		{
+			name: "profile sets some",
+			args: []string{"--profile", "dev"},
+			actions: []otiumtest.Action{
+				otiumtest.Next(), otiumtest.Set("replicas", "1"),
+			},
+			wantVars: map[string]string{"region": "eu-west-1", "replicas": "1"},
+		},
+		{
+			name:     "command-line wins",
+			args:     []string{"--region", "us-east-1", "--profile", "prod"},
+			actions:  []otiumtest.Action{otiumtest.Next()},
+			wantVars: map[string]string{"region": "us-east-1", "replicas": "3"},
+		},
+		{
+			name:    "invalid value",
+			args:    []string{"--profile", "bad"},
+			wantErr: `profile bad: variable "replicas": replicas: want 1 or 3; have "many"`,
+		},
+		{
+			name:    "unknown profile",
+			args:    []string{"--profile", "staging"},
+			wantErr: `profile: .*: unknown profile "staging"; have: \["bad" "dev" "prod"\]`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
+
+func TestProcedure_ExecuteChecks(t *testing.T) {
+	type testCase struct {
+		name          string
+		checks        []otium.Check
+		wantErr       string
+		wantPreFlight bool
+		wantOut       []string
+	}
+
+	pass := otium.CheckFunc("aws-vault logged in", func(ctx context.Context) error {
+		return nil
+	})
+	fail := otium.CheckFunc("VPN connected", func(ctx context.Context) error {
+		return errors.New("no route to host")
+	})
+
+	run := func(t *testing.T, tc testCase) {
+		var preFlight bool
+		pcd := otium.NewProcedure(otium.ProcedureOpts{
+			Title:  "Simple title",
+			Checks: tc.checks,
+			PreFlight: func() (any, error) {
+				preFlight = true
+				return nil, nil
+			},
+		})
+		pcd.AddStep(&otium.Step{Title: "Step A"})
+
+		tr := otiumtest.Run(t, pcd, nil,
+			otiumtest.Line("preflight"), otiumtest.Next())
+
+		if tc.wantErr != "" {
+			qt.Assert(t, qt.ErrorMatches(tr.Err, tc.wantErr))
+		} else {
+			qt.Assert(t, qt.IsNil(tr.Err))
+		}
+		qt.Assert(t, qt.Equals(preFlight, tc.wantPreFlight))
+		for _, want := range tc.wantOut {
+			qt.Assert(t, qt.StringContains(tr.Output, want))
+		}
+	}
+
+	testCases := []testCase{
+		{
+			name:          "no checks",
+			wantPreFlight: true,
+			wantOut:       []string{"(top)>> preflight\nno pre-flight checks\n"},
+		},
+		{
+			name:          "all pass",
+			checks:        []otium.Check{pass},
+			wantPreFlight: true,
+			wantOut: []string{
+				"| pass   | aws-vault logged in                      | - |\n",
+				"(top)>> preflight\n\n## Pre-flight checks\n",
+			},
+		},
+		{
+			name:          "warning does not block",
+			checks:        []otium.Check{pass, fail.AsWarning()},
+			wantPreFlight: true,
+			wantOut: []string{
+				"| warn   | VPN connected                            | no route to host |\n",
+			},
+		},
+		{
+			name:    "failure blocks",
+			checks:  []otium.Check{pass, fail},
+			wantErr: `preflight: 1 blocking checks failed: "VPN connected"`,
+			wantOut: []string{
+				"| FAIL   | VPN connected                            | no route to host |\n",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
+
+func TestProcedure_ExecuteEOFInProgressAsksConfirmation(t *testing.T) {
+	pcd := otium.NewProcedure(otium.ProcedureOpts{Title: "Simple title"})
+	pcd.AddStep(&otium.Step{Title: "step 1"})
+	pcd.AddStep(&otium.Step{Title: "step 2"})
+
+	// After the actions, the procedure sees EOF, as if the user entered
+	// Ctrl-D, both at the top level REPL and at the confirmation.
+	tr := otiumtest.Run(t, pcd, nil, otiumtest.Next())
+
+	qt.Assert(t, qt.ErrorIs(tr.Err, otium.ErrQuit))
+	qt.Assert(t, qt.StringContains(tr.Output, `(top)>> 
+(top) The procedure is in progress (next step: 2 of 2)
+(top) Quit anyway? [y/N] 
+`))
+}
+
+// brokenPrompter is an otium.Prompter whose input breaks after having
+// answered lines.
+type brokenPrompter struct {
+	lines []string
+}
+
+func (bp *brokenPrompter) Prompt(prompt string) (string, error) {
+	if len(bp.lines) == 0 {
+		return "", errors.New("input/output error")
+	}
+	line := bp.lines[0]
+	bp.lines = bp.lines[1:]
+	return line, nil
+}
+
+func (bp *brokenPrompter) PromptWithSuggestion(prompt string, text string, pos int) (string, error) {
+	return bp.Prompt(prompt)
+}
+
+func (brokenPrompter) SetCompleter(f otium.Completer) {}
+
+func (brokenPrompter) AppendHistory(item string) {}
+
+func (brokenPrompter) Close() error { return nil }
+
+func TestProcedure_ExecutePromptErrorIsReturned(t *testing.T) {
+	pcd := otium.NewProcedure(otium.ProcedureOpts{
+		Title:    "Simple title",
+		Stdout:   io.Discard,
+		Prompter: &brokenPrompter{},
+		StateDir: t.TempDir(),
+	})
+	pcd.AddStep(&otium.Step{Title: "step 1"})
+
+	err := pcd.Execute(osArgs)
+
+	qt.Assert(t, qt.ErrorMatches(err, "reading the command: input/output error"))
+	qt.Assert(t, qt.Equals(otium.ExitCode(err), otium.ExitError))
+}
+
+func TestProcedure_ExecuteSubPromptErrorIsReturned(t *testing.T) {
+	type testCase struct {
+		name    string
+		step    *otium.Step
+		wantErr string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		var out bytes.Buffer
+		pcd := otium.NewProcedure(otium.ProcedureOpts{
+			Title:    "Simple title",
+			Stdout:   &out,
+			Stderr:   &out,
+			Prompter: &brokenPrompter{lines: []string{"next"}},
+			StateDir: t.TempDir(),
+		})
+		pcd.AddStep(tc.step)
+
+		err := pcd.Execute(osArgs)
+
+		// The sub REPL returns to the top level, whose prompt is broken too.
+		qt.Assert(t, qt.ErrorMatches(err, "reading the command: input/output error"))
+		qt.Assert(t, qt.StringContains(out.String(), tc.wantErr))
+	}
+
+	testCases := []testCase{
+		{
+			name:    "input",
+			step:    &otium.Step{Title: "step 1", Vars: []otium.Variable{{Name: "fruit"}}},
+			wantErr: "reading the input: input/output error",
+		},
+		{
+			name:    "checklist",
+			step:    &otium.Step{Title: "step 1", Checklist: []string{"apples"}},
+			wantErr: "reading the checklist: input/output error",
+		},
+		{
+			name: "verify",
+			step: &otium.Step{
+				Title: "step 1",
+				Verify: func(ctx context.Context, bag otium.Bag, uctx any) error {
+					return errors.New("not yet")
+				},
+			},
+			wantErr: "reading the verification: input/output error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
+
+func TestProcedure_ExecuteCustomCommandWritesToStdout(t *testing.T) {
+	pcd := otium.NewProcedure(otium.ProcedureOpts{
+		Title: "Simple title",
+		Commands: []otium.Command{{
+			Name: "greet",
+			Run: func(out io.Writer, args []string, bag otium.Bag, uctx any) error {
+				fmt.Fprintf(out, "hello %s\n", args[0])
+				return nil
+			},
+		}},
+	})
+	pcd.AddStep(&otium.Step{Title: "step 1"})
+
+	tr := otiumtest.Run(t, pcd, nil, otiumtest.Line("greet joe"))
+
+	qt.Assert(t, qt.IsNil(tr.Err))
+	qt.Assert(t, qt.StringContains(tr.Output, "(top)>> greet joe\nhello joe\n"))
+}
@@ -0,0 +1,106 @@
+package otium
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// profile is a named set of values of bag variables, selected with flag
+// --profile.
+type profile struct {
+	name string
+	path string
+	vals map[string]string
+}
+
+// defaultProfilesPath returns the path of the profiles file of the procedure
+// called name. It follows the XDG Base Directory Specification:
+// $XDG_CONFIG_HOME/otium/name/profiles.json, defaulting to
+// $HOME/.config/otium/name/profiles.json.
+func defaultProfilesPath(name string) (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "otium", name, "profiles.json"), nil
+}
+
+// profilesPath returns the path of the profiles file of pcd: field
+// ProfilesFile if set, otherwise the default one.
+func (pcd *Procedure) profilesPath() (string, error) {
+	if pcd.ProfilesFile != "" {
+		return pcd.ProfilesFile, nil
+	}
+	return defaultProfilesPath(pcd.Name)
+}
+
+// loadProfile reads the profile called name from the profiles file path. The
+// file is a JSON object mapping each profile name to the values of the
+// variables, for example:
+//
+//	{
+//	    "dev":  {"region": "eu-west-1", "replicas": "1"},
+//	    "prod": {"region": "eu-central-1", "replicas": "3"}
+//	}
+func loadProfile(path, name string) (profile, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return profile{}, fmt.Errorf("profile: %s", err)
+	}
+	var profiles map[string]map[string]string
+	if err := json.Unmarshal(buf, &profiles); err != nil {
+		return profile{}, fmt.Errorf("profile: %s: %s", path, err)
+	}
+	vals, ok := profiles[name]
+	if !ok {
+		names := maps.Keys(profiles)
+		slices.Sort(names)
+		return profile{}, fmt.Errorf("profile: %s: unknown profile %q; have: %q",
+			path, name, names)
+	}
+	return profile{name: name, path: path, vals: vals}, nil
+}
+
+// applyProfile validates the values of prof with the Fn of each variable
+// and puts them in the bag, without overwriting the variables already set
+// (from the command-line). It reports all the invalid values at once.
+func (pcd *Procedure) applyProfile(prof profile) error {
+	keys := maps.Keys(prof.vals)
+	slices.Sort(keys)
+	var errs []error
+	for _, key := range keys {
+		val := prof.vals[key]
+		variable, ok := pcd.bag.bag[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("profile %s: unknown variable %q", prof.name, key))
+			continue
+		}
+		if variable.set {
+			continue
+		}
+		if err := variable.Fn(val); err != nil {
+			errs = append(errs, fmt.Errorf("profile %s: variable %q: %s", prof.name, key, err))
+			continue
+		}
+		pcd.bag.Put(key, val)
+	}
+	return errors.Join(errs...)
+}
+
+// fromProfile returns true if the current value of key is the one set by
+// the active profile.
+func (pcd *Procedure) fromProfile(key string) bool {
+	val, ok := pcd.profile.vals[key]
+	variable := pcd.bag.bag[key]
+	return ok && variable.set && variable.val == val
+}
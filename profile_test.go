@@ -0,0 +1,121 @@
+package otium
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-quicktest/qt"
+)
+
+func writeProfiles(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	qt.Assert(t, qt.IsNil(os.WriteFile(path, []byte(contents), 0o600)))
+	return path
+}
+
+func TestLoadProfile(t *testing.T) {
+	type testCase struct {
+		name     string
+		contents string
+		profile  string
+		want     map[string]string
+		wantErr  string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		path := writeProfiles(t, tc.contents)
+
+		have, err := loadProfile(path, tc.profile)
+
+		if tc.wantErr != "" {
+			qt.Assert(t, qt.ErrorMatches(err, tc.wantErr))
+			return
+		}
+		qt.Assert(t, qt.IsNil(err))
+		qt.Assert(t, qt.Equals(have.name, tc.profile))
+		qt.Assert(t, qt.DeepEquals(have.vals, tc.want))
+	}
+
+	testCases := []testCase{
+		{
+			name:     "found",
+			contents: `{"dev": {"region": "eu-west-1"}, "prod": {"region": "eu-central-1"}}`,
+			profile:  "prod",
+			want:     map[string]string{"region": "eu-central-1"},
+		},
+		{
+			name:     "unknown profile",
+			contents: `{"dev": {}, "prod": {}}`,
+			profile:  "staging",
+			wantErr:  `profile: .*: unknown profile "staging"; have: \["dev" "prod"\]`,
+		},
+		{
+			name:     "invalid JSON",
+			contents: `{"dev": "region"}`,
+			profile:  "dev",
+			wantErr:  `profile: .*: json: cannot unmarshal .*`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
+
+func TestLoadProfileMissingFile(t *testing.T) {
+	_, err := loadProfile(filepath.Join(t.TempDir(), "profiles.json"), "dev")
+
+	qt.Assert(t, qt.ErrorMatches(err, `profile: open .*: no such file or directory`))
+}
+
+func TestApplyProfile(t *testing.T) {
+	pcd := NewProcedure(ProcedureOpts{})
+	isNumber := func(val string) error {
+		if val != "1" && val != "3" {
+			return errors.New("not a number")
+		}
+		return nil
+	}
+	pcd.bag.bag["region"] = Variable{Name: "region", Fn: isNumber}
+	pcd.bag.bag["replicas"] = Variable{Name: "replicas", Fn: isNumber}
+	pcd.bag.bag["zone"] = Variable{Name: "zone", Fn: isNumber}
+	pcd.Put("zone", "1")
+
+	err := pcd.applyProfile(profile{
+		name: "dev",
+		vals: map[string]string{
+			"region":   "eu-west-1",
+			"replicas": "3",
+			"zone":     "3",
+			"color":    "blue",
+		},
+	})
+
+	qt.Assert(t, qt.ErrorMatches(err,
+		`profile dev: unknown variable "color"\n`+
+			`profile dev: variable "region": not a number`))
+	qt.Assert(t, qt.Equals(pcd.bag.bag["replicas"].val, "3"))
+	qt.Assert(t, qt.Equals(pcd.bag.bag["zone"].val, "1"))
+}
+
+func TestCmdVariablesWithProfile(t *testing.T) {
+	var out bytes.Buffer
+	pcd := NewProcedure(ProcedureOpts{Stdout: &out})
+	pcd.profile = profile{
+		name: "dev",
+		path: "profiles.json",
+		vals: map[string]string{"fruit": "mango", "amount": "1"},
+	}
+	pcd.Put("fruit", "mango")
+	pcd.Put("amount", "100")
+
+	cmdVariables(pcd)
+
+	qt.Assert(t, qt.Equals(out.String(), `profile: dev (profiles.json)
+amount (): 100
+fruit (): mango (profile)
+`))
+}
@@ -0,0 +1,112 @@
+package otium
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/peterh/liner"
+)
+
+// Completer returns the completions of line. See [Prompter].
+type Completer func(line string) []string
+
+// Prompter reads the user input, line by line. By default, a [Procedure]
+// uses a Prompter based on https://github.com/peterh/liner, which supports
+// line editing, completion and history. To embed a Procedure in another
+// program or to drive it from a test, see [ProcedureOpts.Prompter] and
+// [NewLinePrompter].
+type Prompter interface {
+	// Prompt shows prompt and returns the line entered by the user, without
+	// the trailing newline. At the end of the input, it returns io.EOF; if
+	// the user aborts the prompt (Ctrl-C), it returns ErrPromptAborted.
+	Prompt(prompt string) (string, error)
+	// PromptWithSuggestion is like Prompt, but pre-fills the line with text,
+	// with the cursor at pos (-1 for the end of text). A Prompter without
+	// line editing can ignore text and pos.
+	PromptWithSuggestion(prompt string, text string, pos int) (string, error)
+	// SetCompleter sets the tab completion function; f can be nil.
+	SetCompleter(f Completer)
+	// AppendHistory adds item to the history of the entered lines.
+	AppendHistory(item string)
+	// Close restores the state of the terminal, if needed.
+	Close() error
+}
+
+// historian is implemented by the Prompters that can persist their history.
+type historian interface {
+	ReadHistory(r io.Reader) (int, error)
+	WriteHistory(w io.Writer) (int, error)
+}
+
+// linerPrompter adapts liner.State to Prompter.
+type linerPrompter struct {
+	*liner.State
+}
+
+// newLinerPrompter returns the default Prompter, reading from the terminal.
+func newLinerPrompter() linerPrompter {
+	term := liner.NewLiner()
+	term.SetCtrlCAborts(true)
+	term.SetTabCompletionStyle(liner.TabPrints)
+	return linerPrompter{term}
+}
+
+func (lp linerPrompter) Prompt(prompt string) (string, error) {
+	line, err := lp.State.Prompt(prompt)
+	return line, linerErr(err)
+}
+
+func (lp linerPrompter) PromptWithSuggestion(prompt string, text string, pos int) (string, error) {
+	line, err := lp.State.PromptWithSuggestion(prompt, text, pos)
+	return line, linerErr(err)
+}
+
+// linerErr maps the errors of liner to the ones documented by Prompter.
+func linerErr(err error) error {
+	if errors.Is(err, liner.ErrPromptAborted) {
+		return ErrPromptAborted
+	}
+	return err
+}
+
+func (lp linerPrompter) SetCompleter(f Completer) {
+	lp.State.SetCompleter(liner.Completer(f))
+}
+
+// linePrompter is a Prompter without line editing, completion nor history.
+type linePrompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// NewLinePrompter returns a [Prompter] that writes the prompt to out and
+// reads the user input line by line from in, without line editing,
+// completion nor history. It is useful to embed a [Procedure] in another
+// program or to drive it from a test, together with [ProcedureOpts.Stdout].
+func NewLinePrompter(in io.Reader, out io.Writer) Prompter {
+	return &linePrompter{in: bufio.NewReader(in), out: out}
+}
+
+func (lp *linePrompter) Prompt(prompt string) (string, error) {
+	if _, err := fmt.Fprint(lp.out, prompt); err != nil {
+		return "", err
+	}
+	line, err := lp.in.ReadString('\n')
+	if err != nil && !(err == io.EOF && line != "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (lp *linePrompter) PromptWithSuggestion(prompt string, text string, pos int) (string, error) {
+	return lp.Prompt(prompt)
+}
+
+func (lp *linePrompter) SetCompleter(f Completer) {}
+
+func (lp *linePrompter) AppendHistory(item string) {}
+
+func (lp *linePrompter) Close() error { return nil }
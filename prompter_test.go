@@ -0,0 +1,15 @@
+package otium
+
+import (
+	"io"
+	"testing"
+
+	"github.com/go-quicktest/qt"
+	"github.com/peterh/liner"
+)
+
+func TestLinerErr(t *testing.T) {
+	qt.Assert(t, qt.ErrorIs(linerErr(liner.ErrPromptAborted), ErrPromptAborted))
+	qt.Assert(t, qt.ErrorIs(linerErr(io.EOF), io.EOF))
+	qt.Assert(t, qt.IsNil(linerErr(nil)))
+}
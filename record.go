@@ -0,0 +1,131 @@
+package otium
+
+import "time"
+
+// RunRecord is the record of what happened during one execution of a
+// [Procedure].
+type RunRecord struct {
+	Procedure string    `json:"procedure"`
+	Title     string    `json:"title"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	// Mode tells if the steps have been run for real. An empty Mode (the
+	// records saved by older versions) means ModeLive.
+	Mode RunMode `json:"mode,omitempty"`
+	// Vars contains the variables collected during the run. The value of a
+	// secret variable is redacted.
+	Vars  map[string]string `json:"vars,omitempty"`
+	Steps []StepRecord      `json:"steps"`
+}
+
+// RunMode is the mode of a [RunRecord].
+type RunMode string
+
+const (
+	// ModeLive means that the steps have been run for real.
+	ModeLive RunMode = "live"
+	// ModeDryRun means that the procedure has been invoked with --dry-run:
+	// no automated step has been run.
+	ModeDryRun RunMode = "dry-run"
+	// ModeRehearsal means that the procedure has been invoked with
+	// --rehearsal or --rehearse: some automated steps have not been run for
+	// real.
+	ModeRehearsal RunMode = "rehearsal"
+)
+
+// live returns true if the run has been done for real. Only live runs are
+// meaningful for the statistics.
+func (run RunRecord) live() bool {
+	return run.Mode == "" || run.Mode == ModeLive
+}
+
+// StepStatus is the status of a [StepRecord].
+type StepStatus string
+
+const (
+	// StatusPending means that the step has not been done (yet).
+	StatusPending StepStatus = "pending"
+	// StatusDone means that the step has been done at the first attempt.
+	StatusDone StepStatus = "done"
+	// StatusRetried means that the step has been done, after one or more
+	// failed attempts.
+	StatusRetried StepStatus = "retried"
+	// StatusFailed means that the last attempt of the step failed.
+	StatusFailed StepStatus = "failed"
+	// StatusSkipped means that the user skipped the step or, during a
+	// rehearsal, that the step has no FakeRun and the human performed it.
+	StatusSkipped StepStatus = "skipped"
+)
+
+// StepRecord is the record of one [Step] of a [RunRecord].
+type StepRecord struct {
+	N      int        `json:"n"`
+	Title  string     `json:"title"`
+	Manual bool       `json:"manual"`
+	Status StepStatus `json:"status"`
+	// Attempts is the number of times the Run function of an automated step
+	// has been called.
+	Attempts int `json:"attempts,omitempty"`
+	// Start is when the step has been visited for the first time; the zero
+	// time (0001-01-01T00:00:00Z) if never visited.
+	Start time.Time `json:"start"`
+	// End is when the step has been done; the zero time if not done.
+	End       time.Time    `json:"end"`
+	Checklist []ItemRecord `json:"checklist,omitempty"`
+	// Override is the reason given by the human to override a failed
+	// [Step.Verify].
+	Override string `json:"override,omitempty"`
+}
+
+// Duration returns the time spent on the step, or 0 if the step has not
+// been done.
+func (rec StepRecord) Duration() time.Duration {
+	if rec.Start.IsZero() || rec.End.IsZero() {
+		return 0
+	}
+	return rec.End.Sub(rec.Start)
+}
+
+// ItemRecord is the record of one item of [Step.Checklist]. An item is
+// acknowledged either when it is ticked (Done is set) or when it is waived
+// (Waived contains the reason), but not both: the last action wins.
+type ItemRecord struct {
+	Item string `json:"item"`
+	// Done is when the item has been ticked; the zero time
+	// (0001-01-01T00:00:00Z) if not ticked.
+	Done   time.Time `json:"done"`
+	Waived string    `json:"waived,omitempty"`
+}
+
+// tick marks the item as done at t, clearing a previous waiver.
+func (item *ItemRecord) tick(t time.Time) {
+	item.Done, item.Waived = t, ""
+}
+
+// waive marks the item as waived for reason, clearing a previous tick.
+func (item *ItemRecord) waive(reason string) {
+	item.Done, item.Waived = time.Time{}, reason
+}
+
+// acked returns true if the item has been either ticked or waived.
+func (item ItemRecord) acked() bool {
+	return !item.Done.IsZero() || item.Waived != ""
+}
+
+// newRunRecord returns a RunRecord with one StepRecord for each step.
+func newRunRecord(name string, steps []*Step) RunRecord {
+	rec := RunRecord{Procedure: name, Steps: make([]StepRecord, len(steps))}
+	for i, step := range steps {
+		rec.Steps[i] = StepRecord{
+			N:      i + 1,
+			Title:  step.Title,
+			Manual: step.Run == nil,
+			Status: StatusPending,
+		}
+		for _, item := range step.Checklist {
+			rec.Steps[i].Checklist = append(rec.Steps[i].Checklist,
+				ItemRecord{Item: item})
+		}
+	}
+	return rec
+}
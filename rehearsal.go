@@ -0,0 +1,65 @@
+package otium
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const rehearsalBanner = "**REHEARSAL**: the steps marked with 🎭 are not run for real."
+
+// rehearsed returns true if the step at index i (0-based) is rehearsed, that
+// is, if it is automated and the procedure has been invoked with --rehearsal
+// or with --rehearse selecting it.
+func (pcd *Procedure) rehearsed(i int) bool {
+	return pcd.steps[i].Run != nil && i < len(pcd.rehearse) && pcd.rehearse[i]
+}
+
+// rehearsing returns true if at least one step is rehearsed.
+func (pcd *Procedure) rehearsing() bool {
+	for i := range pcd.steps {
+		if pcd.rehearsed(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// icon returns the icon of the step at index i (0-based).
+func (pcd *Procedure) icon(i int) string {
+	if pcd.rehearsed(i) {
+		return "🎭"
+	}
+	return pcd.steps[i].Icon()
+}
+
+// parseRehearse parses the value of flag --rehearse and returns the 0-based
+// indexes of the steps to rehearse. Only the automated steps can be
+// rehearsed.
+func (pcd *Procedure) parseRehearse(val string) ([]int, error) {
+	idxs, err := parseSteps(val, len(pcd.steps))
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range idxs {
+		if pcd.steps[i].Run == nil {
+			return nil, fmt.Errorf("step %d is manual; only the automated steps can be rehearsed", i+1)
+		}
+	}
+	return idxs, nil
+}
+
+// parseSteps parses the comma-separated list of 1-based step numbers s and
+// returns the corresponding 0-based indexes.
+func parseSteps(s string, count int) ([]int, error) {
+	var idxs []int
+	for _, field := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || n < 1 || n > count {
+			return nil, fmt.Errorf("invalid step %q; want a number in [1, %d]",
+				field, count)
+		}
+		idxs = append(idxs, n-1)
+	}
+	return idxs, nil
+}
@@ -0,0 +1,56 @@
+package otium
+
+import (
+	"testing"
+
+	"github.com/go-quicktest/qt"
+)
+
+func TestParseRehearse(t *testing.T) {
+	pcd := NewProcedure(ProcedureOpts{})
+	pcd.AddStep(&Step{Title: "one"})
+	pcd.AddStep(&Step{Title: "two", Run: func(bag Bag, uctx any) error { return nil }})
+
+	have, err := pcd.parseRehearse("2")
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.DeepEquals(have, []int{1}))
+
+	_, err = pcd.parseRehearse("1,2")
+	qt.Assert(t, qt.ErrorMatches(err,
+		"step 1 is manual; only the automated steps can be rehearsed"))
+}
+
+func TestParseSteps(t *testing.T) {
+	type testCase struct {
+		name    string
+		in      string
+		want    []int
+		wantErr string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		have, err := parseSteps(tc.in, 4)
+
+		if tc.wantErr != "" {
+			qt.Assert(t, qt.ErrorMatches(err, tc.wantErr))
+			return
+		}
+		qt.Assert(t, qt.IsNil(err))
+		qt.Assert(t, qt.DeepEquals(have, tc.want))
+	}
+
+	testCases := []testCase{
+		{name: "one", in: "2", want: []int{1}},
+		{name: "many", in: "1, 4", want: []int{0, 3}},
+		{name: "out of range", in: "1,5",
+			wantErr: `invalid step "5"; want a number in \[1, 4\]`},
+		{name: "not a number", in: "a",
+			wantErr: `invalid step "a"; want a number in \[1, 4\]`},
+		{name: "empty", in: "",
+			wantErr: `invalid step ""; want a number in \[1, 4\]`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
@@ -0,0 +1,211 @@
+package otium
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// topPrompt is the prompt of the top level REPL.
+const topPrompt = "(top)>> "
+
+// inputRecord is one line entered by the operator, as saved by --record and
+// read back by --replay.
+type inputRecord struct {
+	// Prompt is the prompt the line has been entered at.
+	Prompt string `json:"prompt"`
+	// Line is the line entered; if Secret, it is redacted.
+	Line string `json:"line"`
+	// Secret is true if Line contained the value of a secret variable.
+	Secret bool `json:"secret,omitempty"`
+}
+
+// recordingPrompter is a Prompter that saves each line entered by the
+// operator to a file, one JSON object per line. The values of the secret
+// variables are never saved.
+type recordingPrompter struct {
+	Prompter
+	out io.Writer
+	bag Bag
+	fi  *os.File
+	enc *json.Encoder
+	// editing is the key of the variable being edited by the edit command.
+	editing string
+	// stopped is true if the recording failed.
+	stopped bool
+}
+
+// newRecordingPrompter returns a Prompter that records to path each line
+// entered with term. The caller must close it.
+func newRecordingPrompter(term Prompter, out io.Writer, bag Bag, path string,
+) (*recordingPrompter, error) {
+	fi, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("record: %s", err)
+	}
+	return &recordingPrompter{
+		Prompter: term, out: out, bag: bag, fi: fi, enc: json.NewEncoder(fi),
+	}, nil
+}
+
+func (rp *recordingPrompter) Prompt(prompt string) (string, error) {
+	line, err := rp.Prompter.Prompt(prompt)
+	if err != nil {
+		return line, err
+	}
+	rp.record(prompt, line)
+	return line, nil
+}
+
+func (rp *recordingPrompter) PromptWithSuggestion(prompt string, text string, pos int) (string, error) {
+	line, err := rp.Prompter.PromptWithSuggestion(prompt, text, pos)
+	if err != nil {
+		return line, err
+	}
+	rp.record(prompt, line)
+	return line, nil
+}
+
+// record saves line, entered at prompt. Since each record is written
+// immediately, the recording survives a crash of the program. Failing to
+// record must not interrupt the procedure, so on error record warns and
+// stops recording.
+func (rp *recordingPrompter) record(prompt, line string) {
+	if rp.stopped {
+		return
+	}
+	rec := inputRecord{Prompt: prompt, Line: line}
+	fields := strings.Fields(line)
+	switch {
+	case len(fields) > 2 && fields[0] == "set" && rp.bag.bag[fields[1]].Secret:
+		rec.Line, rec.Secret = "set "+fields[1]+" ", true
+	case prompt == "(edit)>> " && rp.bag.bag[rp.editing].Secret:
+		rec.Line, rec.Secret = "", true
+	}
+	rp.editing = ""
+	if prompt == topPrompt && len(fields) == 2 && fields[0] == "edit" {
+		rp.editing = fields[1]
+	}
+	if err := rp.enc.Encode(rec); err != nil {
+		rp.stopped = true
+		fmt.Fprintf(rp.out, "(record) Warning: recording stopped: %s\n", err)
+	}
+}
+
+func (rp *recordingPrompter) Close() error {
+	return rp.fi.Close()
+}
+
+// loadRecording reads the lines recorded by --record in path.
+func loadRecording(path string) ([]inputRecord, error) {
+	fi, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("replay: %s", err)
+	}
+	defer fi.Close()
+	var recs []inputRecord
+	scanner := bufio.NewScanner(fi)
+	for n := 1; scanner.Scan(); n++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var rec inputRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("replay: %s: line %d: %s", path, n, err)
+		}
+		recs = append(recs, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("replay: %s: %s", path, err)
+	}
+	return recs, nil
+}
+
+// replayPrompter is a Prompter that answers each prompt with the line
+// recorded for it, echoing it to out as if the operator entered it. It
+// pauses, asking the operator with the wrapped Prompter, when:
+//   - the recorded line is for a different prompt (the procedure asks
+//     something that was not asked when recording);
+//   - the recorded line contains the value of a secret variable;
+//   - a step failed (see pause).
+//
+// When the recorded lines are exhausted, the operator continues manually.
+type replayPrompter struct {
+	Prompter
+	out  io.Writer
+	recs []inputRecord
+	// last is the record consumed last.
+	last   inputRecord
+	paused bool
+	ended  bool
+}
+
+func newReplayPrompter(term Prompter, out io.Writer, recs []inputRecord) *replayPrompter {
+	return &replayPrompter{Prompter: term, out: out, recs: recs}
+}
+
+func (rp *replayPrompter) Prompt(prompt string) (string, error) {
+	if line, ok := rp.next(prompt); ok {
+		return line, nil
+	}
+	return rp.Prompter.Prompt(prompt)
+}
+
+func (rp *replayPrompter) PromptWithSuggestion(prompt string, text string, pos int) (string, error) {
+	if line, ok := rp.next(prompt); ok {
+		return line, nil
+	}
+	if rp.last.Secret && rp.last.Prompt == prompt && rp.last.Line != "" {
+		// Suggest the redacted line, for example "set password ".
+		text, pos = rp.last.Line, -1
+	}
+	return rp.Prompter.PromptWithSuggestion(prompt, text, pos)
+}
+
+// next returns the recorded line for prompt and true, or false if the
+// operator must enter the line.
+func (rp *replayPrompter) next(prompt string) (string, bool) {
+	rp.last = inputRecord{}
+	if rp.paused {
+		rp.paused = false
+		fmt.Fprintf(rp.out, "(replay) Paused: a step failed; enter the next command, then the replay continues\n")
+		return "", false
+	}
+	if prompt == topPrompt {
+		// Back at the top level earlier than when recording: the lines
+		// recorded for the sub REPLs are no longer needed.
+		for len(rp.recs) > 0 && rp.recs[0].Prompt != topPrompt {
+			rp.recs = rp.recs[1:]
+		}
+	}
+	if len(rp.recs) == 0 {
+		if !rp.ended {
+			rp.ended = true
+			fmt.Fprintf(rp.out, "(replay) End of the recording; continue manually\n")
+		}
+		return "", false
+	}
+	rec := rp.recs[0]
+	if rec.Prompt != prompt {
+		fmt.Fprintf(rp.out, "(replay) Paused: nothing recorded for this prompt; enter the input\n")
+		return "", false
+	}
+	rp.recs = rp.recs[1:]
+	rp.last = rec
+	if rec.Secret {
+		fmt.Fprintf(rp.out, "(replay) Paused: the recorded input is secret; enter it again\n")
+		return "", false
+	}
+	fmt.Fprintf(rp.out, "%s%s\n", prompt, rec.Line)
+	return rec.Line, true
+}
+
+// pause gives back control to the operator for the next prompt. It is called
+// when a step fails, so that the operator can decide what to do (for
+// example, retry or skip the step).
+func (rp *replayPrompter) pause() {
+	rp.paused = len(rp.recs) > 0
+}
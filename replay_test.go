@@ -0,0 +1,193 @@
+package otium
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-quicktest/qt"
+)
+
+func TestRecordingPrompterRedactsSecrets(t *testing.T) {
+	bag := NewBag()
+	bag.bag["user"] = Variable{Name: "user"}
+	bag.bag["password"] = Variable{Name: "password", Secret: true}
+	input := strings.Join([]string{
+		"set user joe",
+		"set password 1234",
+		"edit password",
+		"5678",
+		"next",
+	}, "\n") + "\n"
+	term := NewLinePrompter(strings.NewReader(input), &bytes.Buffer{})
+	path := filepath.Join(t.TempDir(), "recording")
+
+	recorder, err := newRecordingPrompter(term, io.Discard, bag, path)
+	qt.Assert(t, qt.IsNil(err))
+	for _, prompt := range []string{topPrompt, "(input)>> ", topPrompt, "(edit)>> ", topPrompt} {
+		_, err := recorder.Prompt(prompt)
+		qt.Assert(t, qt.IsNil(err))
+	}
+	qt.Assert(t, qt.IsNil(recorder.Close()))
+
+	have, err := loadRecording(path)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.DeepEquals(have, []inputRecord{
+		{Prompt: topPrompt, Line: "set user joe"},
+		{Prompt: "(input)>> ", Line: "set password ", Secret: true},
+		{Prompt: topPrompt, Line: "edit password"},
+		{Prompt: "(edit)>> ", Line: "", Secret: true},
+		{Prompt: topPrompt, Line: "next"},
+	}))
+	data, err := os.ReadFile(path)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Not(qt.StringContains(string(data), "1234")))
+	qt.Assert(t, qt.Not(qt.StringContains(string(data), "5678")))
+}
+
+func TestRecordingPrompterWriteErrorWarns(t *testing.T) {
+	term := NewLinePrompter(strings.NewReader("list\nnext\n"), &bytes.Buffer{})
+	var out bytes.Buffer
+	path := filepath.Join(t.TempDir(), "recording")
+	recorder, err := newRecordingPrompter(term, &out, NewBag(), path)
+	qt.Assert(t, qt.IsNil(err))
+	// Make the next writes fail.
+	qt.Assert(t, qt.IsNil(recorder.fi.Close()))
+
+	for _, want := range []string{"list", "next"} {
+		line, err := recorder.Prompt(topPrompt)
+		qt.Assert(t, qt.IsNil(err))
+		qt.Assert(t, qt.Equals(line, want))
+	}
+
+	// Warned only once.
+	qt.Assert(t, qt.Equals(strings.Count(out.String(), "(record) Warning: recording stopped:"), 1))
+}
+
+func TestLoadRecordingInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recording")
+	err := os.WriteFile(path, []byte("{\"prompt\": \"(top)>> \", \"line\": \"next\"}\nnext\n"), 0o600)
+	qt.Assert(t, qt.IsNil(err))
+
+	_, err = loadRecording(path)
+
+	qt.Assert(t, qt.ErrorMatches(err, `replay: .*: line 2: invalid character .*`))
+}
+
+func TestReplayPrompter(t *testing.T) {
+	type call struct {
+		prompt string
+		pause  bool // Call pause before prompting.
+		want   string
+	}
+	type testCase struct {
+		name    string
+		recs    []inputRecord
+		manual  string // The lines entered by the operator.
+		calls   []call
+		wantOut string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		var out bytes.Buffer
+		term := NewLinePrompter(strings.NewReader(tc.manual), &out)
+		replay := newReplayPrompter(term, &out, tc.recs)
+
+		for _, c := range tc.calls {
+			if c.pause {
+				replay.pause()
+			}
+			have, err := replay.PromptWithSuggestion(c.prompt, "", -1)
+			qt.Assert(t, qt.IsNil(err))
+			qt.Assert(t, qt.Equals(have, c.want))
+		}
+		qt.Assert(t, qt.Equals(out.String(), tc.wantOut))
+	}
+
+	testCases := []testCase{
+		{
+			name: "all recorded, then manual",
+			recs: []inputRecord{
+				{Prompt: topPrompt, Line: "next"},
+				{Prompt: "(input)>> ", Line: "set fruit banana"},
+			},
+			manual: "quit\n",
+			calls: []call{
+				{prompt: topPrompt, want: "next"},
+				{prompt: "(input)>> ", want: "set fruit banana"},
+				{prompt: topPrompt, want: "quit"},
+			},
+			wantOut: "(top)>> next\n" +
+				"(input)>> set fruit banana\n" +
+				"(replay) End of the recording; continue manually\n" +
+				"(top)>> ",
+		},
+		{
+			name: "prompt not recorded",
+			recs: []inputRecord{
+				{Prompt: topPrompt, Line: "next"},
+				{Prompt: topPrompt, Line: "next"},
+			},
+			manual: "done all\n",
+			calls: []call{
+				{prompt: topPrompt, want: "next"},
+				{prompt: "(check)>> ", want: "done all"},
+				{prompt: topPrompt, want: "next"},
+			},
+			wantOut: "(top)>> next\n" +
+				"(replay) Paused: nothing recorded for this prompt; enter the input\n" +
+				"(check)>> " +
+				"(top)>> next\n",
+		},
+		{
+			name: "recorded prompt not asked",
+			recs: []inputRecord{
+				{Prompt: topPrompt, Line: "next"},
+				{Prompt: "(input)>> ", Line: "set fruit banana"},
+				{Prompt: topPrompt, Line: "quit"},
+			},
+			calls: []call{
+				{prompt: topPrompt, want: "next"},
+				{prompt: topPrompt, want: "quit"},
+			},
+			wantOut: "(top)>> next\n" +
+				"(top)>> quit\n",
+		},
+		{
+			name: "secret",
+			recs: []inputRecord{
+				{Prompt: "(input)>> ", Line: "set password ", Secret: true},
+			},
+			manual: "set password 1234\n",
+			calls: []call{
+				{prompt: "(input)>> ", want: "set password 1234"},
+			},
+			wantOut: "(replay) Paused: the recorded input is secret; enter it again\n" +
+				"(input)>> ",
+		},
+		{
+			name: "step failed",
+			recs: []inputRecord{
+				{Prompt: topPrompt, Line: "next"},
+				{Prompt: topPrompt, Line: "next"},
+			},
+			manual: "skip\n",
+			calls: []call{
+				{prompt: topPrompt, want: "next"},
+				{prompt: topPrompt, pause: true, want: "skip"},
+				{prompt: topPrompt, want: "next"},
+			},
+			wantOut: "(top)>> next\n" +
+				"(replay) Paused: a step failed; enter the next command, then the replay continues\n" +
+				"(top)>> " +
+				"(top)>> next\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
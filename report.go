@@ -0,0 +1,140 @@
+package otium
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+const redacted = "<redacted>"
+
+// finish completes the run record and prints the summary report. If
+// reportPath is not empty, it also writes the report to that file, in JSON
+// format if the file extension is ".json", in markdown format otherwise.
+func (pcd *Procedure) finish(reportPath string) error {
+	pcd.closeStep()
+	pcd.run.End = pcd.now()
+	pcd.run.Vars = collectVars(pcd.bag)
+
+	writeReportMarkdown(pcd.out, pcd.run)
+
+	// A dry run or a rehearsal would skew the statistics, so we do not save
+	// it.
+	if pcd.run.live() {
+		// Failing to save the run record must not turn a successful
+		// procedure into a failed one, so we only warn.
+		dir, err := pcd.runLogDir()
+		if err == nil {
+			err = saveRunRecord(dir, pcd.run)
+		}
+		if err != nil {
+			fmt.Fprintf(pcd.out, "(top) Warning: cannot save the run record: %s\n", err)
+		}
+	}
+
+	if reportPath == "" {
+		return nil
+	}
+	if err := writeReportFile(reportPath, pcd.run); err != nil {
+		return fmt.Errorf("report: %s", err)
+	}
+	fmt.Fprintf(pcd.out, "(top) Report written to %s\n", reportPath)
+	return nil
+}
+
+// collectVars returns the variables set in bag, with the values of the
+// secret variables redacted.
+func collectVars(bag Bag) map[string]string {
+	vars := make(map[string]string)
+	for k, v := range bag.bag {
+		if !v.set {
+			continue
+		}
+		if v.Secret {
+			vars[k] = redacted
+			continue
+		}
+		vars[k] = v.val
+	}
+	return vars
+}
+
+func writeReportFile(path string, run RunRecord) error {
+	fi, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		err = writeReportJSON(fi, run)
+	} else {
+		err = writeReportMarkdown(fi, run)
+	}
+	if err != nil {
+		fi.Close()
+		return err
+	}
+	return fi.Close()
+}
+
+func writeReportJSON(wr io.Writer, run RunRecord) error {
+	enc := json.NewEncoder(wr)
+	enc.SetIndent("", "  ")
+	return enc.Encode(run)
+}
+
+// writeReportMarkdown writes run to wr as a markdown report. It returns the
+// first write error, if any.
+func writeReportMarkdown(wr io.Writer, run RunRecord) error {
+	bw := bufio.NewWriter(wr)
+	wr = bw
+	fmt.Fprintf(wr, "\n## Summary: %s\n\n", run.Title)
+	switch run.Mode {
+	case ModeDryRun:
+		fmt.Fprintf(wr, "%s\n\n", dryRunBanner)
+	case ModeRehearsal:
+		fmt.Fprintf(wr, "%s\n\n", rehearsalBanner)
+	}
+	fmt.Fprintf(wr, "Started: %s\n", run.Start.Format(time.RFC3339))
+	fmt.Fprintf(wr, "Elapsed: %s\n\n", formatDuration(run.End.Sub(run.Start)))
+
+	fmt.Fprintf(wr, "| %2s | %-40s | %-9s | %-7s | %8s |\n",
+		"#", "Step", "Type", "Status", "Duration")
+	fmt.Fprintf(wr, "|----|%s|-----------|---------|----------|\n",
+		strings.Repeat("-", 42))
+	for _, step := range run.Steps {
+		kind := "automated"
+		if step.Manual {
+			kind = "manual"
+		}
+		fmt.Fprintf(wr, "| %2d | %-40s | %-9s | %-7s | %8s |\n",
+			step.N, step.Title, kind, step.Status,
+			formatDuration(step.Duration()))
+	}
+
+	if len(run.Vars) > 0 {
+		fmt.Fprintf(wr, "\n### Variables\n\n")
+		keys := maps.Keys(run.Vars)
+		slices.Sort(keys)
+		for _, k := range keys {
+			fmt.Fprintf(wr, "- %s: %s\n", k, run.Vars[k])
+		}
+	}
+	fmt.Fprintln(wr)
+	return bw.Flush()
+}
+
+// formatDuration returns d rounded to the second, or "-" if d is zero.
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,135 @@
+package otium
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-quicktest/qt"
+)
+
+func newTestRunRecord() RunRecord {
+	start := time.Date(2023, 8, 1, 10, 0, 0, 0, time.UTC)
+	return RunRecord{
+		Procedure: "fruits",
+		Title:     "My preferred fruits",
+		Start:     start,
+		End:       start.Add(5 * time.Minute),
+		Vars:      map[string]string{"fruit": "mango", "token": redacted},
+		Steps: []StepRecord{
+			{
+				N: 1, Title: "Red fruits", Manual: true, Status: StatusDone,
+				Start: start, End: start.Add(90 * time.Second),
+			},
+			{
+				N: 2, Title: "Blue fruits", Status: StatusRetried, Attempts: 2,
+				Start: start.Add(2 * time.Minute), End: start.Add(3 * time.Minute),
+			},
+			{N: 3, Title: "Green fruits", Manual: true, Status: StatusSkipped},
+			{N: 4, Title: "Yellow fruits", Status: StatusPending},
+		},
+	}
+}
+
+func TestWriteReportMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := writeReportMarkdown(&buf, newTestRunRecord())
+	qt.Assert(t, qt.IsNil(err))
+
+	want := `
+## Summary: My preferred fruits
+
+Started: 2023-08-01T10:00:00Z
+Elapsed: 5m0s
+
+|  # | Step                                     | Type      | Status  | Duration |
+|----|------------------------------------------|-----------|---------|----------|
+|  1 | Red fruits                               | manual    | done    |    1m30s |
+|  2 | Blue fruits                              | automated | retried |     1m0s |
+|  3 | Green fruits                             | manual    | skipped |        - |
+|  4 | Yellow fruits                            | automated | pending |        - |
+
+### Variables
+
+- fruit: mango
+- token: <redacted>
+
+`
+	qt.Assert(t, qt.Equals(buf.String(), want))
+}
+
+func TestWriteReportMarkdownBanner(t *testing.T) {
+	type testCase struct {
+		name       string
+		mode       RunMode
+		wantBanner string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		var buf bytes.Buffer
+		run := newTestRunRecord()
+		run.Mode = tc.mode
+
+		err := writeReportMarkdown(&buf, run)
+		qt.Assert(t, qt.IsNil(err))
+
+		qt.Assert(t, qt.StringContains(buf.String(),
+			"## Summary: My preferred fruits\n\n"+tc.wantBanner+"Started:"))
+	}
+
+	testCases := []testCase{
+		{name: "live", mode: ModeLive},
+		{name: "dry run", mode: ModeDryRun, wantBanner: dryRunBanner + "\n\n"},
+		{name: "rehearsal", mode: ModeRehearsal, wantBanner: rehearsalBanner + "\n\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
+
+func TestWriteReportFileJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	run := newTestRunRecord()
+
+	err := writeReportFile(path, run)
+	qt.Assert(t, qt.IsNil(err))
+
+	buf, err := os.ReadFile(path)
+	qt.Assert(t, qt.IsNil(err))
+	var have RunRecord
+	err = json.Unmarshal(buf, &have)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.DeepEquals(have, run))
+}
+
+func TestCollectVarsRedactsSecrets(t *testing.T) {
+	bag := NewBag()
+	bag.bag["token"] = Variable{Name: "token", Secret: true}
+	bag.bag["unset"] = Variable{Name: "unset"}
+	bag.Put("token", "s3cr3t")
+	bag.Put("fruit", "mango")
+
+	have := collectVars(bag)
+
+	qt.Assert(t, qt.DeepEquals(have,
+		map[string]string{"fruit": "mango", "token": redacted}))
+}
+
+// failingWriter is an io.Writer that always fails.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("no space left on device")
+}
+
+func TestWriteReportMarkdownReturnsWriteError(t *testing.T) {
+	err := writeReportMarkdown(failingWriter{}, newTestRunRecord())
+
+	qt.Assert(t, qt.ErrorMatches(err, "no space left on device"))
+}
@@ -0,0 +1,135 @@
+package otium
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultStateDir returns the directory where otium keeps by default the
+// state (run records, history, ...) of the procedure called name. It follows
+// the XDG Base Directory Specification: $XDG_STATE_HOME/otium/name,
+// defaulting to $HOME/.local/state/otium/name.
+func defaultStateDir(name string) (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "otium", name), nil
+}
+
+// stateDir returns the state directory of pcd: field StateDir if set,
+// otherwise the default one.
+func (pcd *Procedure) stateDir() (string, error) {
+	if pcd.StateDir != "" {
+		return pcd.StateDir, nil
+	}
+	return defaultStateDir(pcd.Name)
+}
+
+// runLogDir returns the directory containing the run records of pcd.
+func (pcd *Procedure) runLogDir() (string, error) {
+	dir, err := pcd.stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "runs"), nil
+}
+
+// saveRunRecord writes run as a JSON file in dir, creating dir if needed.
+func saveRunRecord(dir string, run RunRecord) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	name := run.Start.UTC().Format("2006-01-02T15-04-05.000Z") + ".json"
+	buf, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, name), buf, 0o600)
+}
+
+// loadRunRecords reads all the run records in dir, sorted by start time.
+// A non-existing dir is not an error.
+func loadRunRecords(dir string) ([]RunRecord, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var runs []RunRecord
+	var errs []error
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		run, err := readRunRecord(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !run.live() {
+			// For example, a report of a dry run copied in dir.
+			continue
+		}
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].Start.Before(runs[j].Start)
+	})
+	return runs, errors.Join(errs...)
+}
+
+func readRunRecord(path string) (RunRecord, error) {
+	var run RunRecord
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return run, err
+	}
+	if err := json.Unmarshal(buf, &run); err != nil {
+		return run, &fs.PathError{Op: "decode", Path: path, Err: err}
+	}
+	return run, nil
+}
+
+// stepDurations returns the durations of the step with the given title, for
+// all the runs in which the step has been done.
+func stepDurations(runs []RunRecord, title string) []time.Duration {
+	var durations []time.Duration
+	for _, run := range runs {
+		for _, step := range run.Steps {
+			if step.Title != title {
+				continue
+			}
+			if step.Status == StatusDone || step.Status == StatusRetried {
+				durations = append(durations, step.Duration())
+			}
+		}
+	}
+	return durations
+}
+
+// median returns the median of durations, or 0 if durations is empty.
+func median(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
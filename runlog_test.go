@@ -0,0 +1,172 @@
+package otium
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-quicktest/qt"
+)
+
+func TestStateDir(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "/state")
+
+	have, err := defaultStateDir("fruits")
+
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(have, "/state/otium/fruits"))
+}
+
+func TestStateDirDefault(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "")
+	t.Setenv("HOME", "/home/joe")
+
+	have, err := defaultStateDir("fruits")
+
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(have, "/home/joe/.local/state/otium/fruits"))
+}
+
+func TestStateDirOverride(t *testing.T) {
+	pcd := NewProcedure(ProcedureOpts{Name: "fruits", StateDir: "/tmp/x"})
+
+	have, err := pcd.runLogDir()
+
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(have, "/tmp/x/runs"))
+}
+
+func TestRunRecordsSaveLoad(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "runs")
+	run1 := newTestRunRecord()
+	run2 := newTestRunRecord()
+	run2.Start = run1.Start.Add(-time.Hour)
+
+	qt.Assert(t, qt.IsNil(saveRunRecord(dir, run1)))
+	qt.Assert(t, qt.IsNil(saveRunRecord(dir, run2)))
+	err := os.WriteFile(filepath.Join(dir, "ignored.txt"), nil, 0o600)
+	qt.Assert(t, qt.IsNil(err))
+
+	have, err := loadRunRecords(dir)
+
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.DeepEquals(have, []RunRecord{run2, run1}))
+}
+
+func TestLoadRunRecordsSkipsNonLiveRuns(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "runs")
+	old := newTestRunRecord() // Saved before RunRecord.Mode existed.
+	live := newTestRunRecord()
+	live.Start = old.Start.Add(time.Hour)
+	live.Mode = ModeLive
+	dry := newTestRunRecord()
+	dry.Start = old.Start.Add(2 * time.Hour)
+	dry.Mode = ModeDryRun
+	rehearsal := newTestRunRecord()
+	rehearsal.Start = old.Start.Add(3 * time.Hour)
+	rehearsal.Mode = ModeRehearsal
+
+	for _, run := range []RunRecord{old, live, dry, rehearsal} {
+		qt.Assert(t, qt.IsNil(saveRunRecord(dir, run)))
+	}
+
+	have, err := loadRunRecords(dir)
+
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.DeepEquals(have, []RunRecord{old, live}))
+}
+
+func TestLoadRunRecordsNonExistingDir(t *testing.T) {
+	have, err := loadRunRecords(filepath.Join(t.TempDir(), "non-existing"))
+
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.HasLen(have, 0))
+}
+
+func TestMedian(t *testing.T) {
+	type testCase struct {
+		name      string
+		durations []time.Duration
+		want      time.Duration
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		qt.Assert(t, qt.Equals(median(tc.durations), tc.want))
+	}
+
+	testCases := []testCase{
+		{name: "empty", want: 0},
+		{name: "odd", durations: []time.Duration{9, 1, 5}, want: 5},
+		{name: "even", durations: []time.Duration{9, 1, 5, 3}, want: 4},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	history := make([]RunRecord, slowMinRuns)
+	for i := range history {
+		history[i] = newTestRunRecord() // "Red fruits" took 1m30s.
+	}
+
+	type testCase struct {
+		name     string
+		expected time.Duration
+		history  []RunRecord
+		want     string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		step := &Step{Title: "Red fruits", ExpectedDuration: tc.expected}
+		qt.Assert(t, qt.Equals(estimate(step, tc.history), tc.want))
+	}
+
+	testCases := []testCase{
+		{
+			name:    "no expected duration",
+			history: history,
+			want:    "",
+		},
+		{
+			name:     "as expected",
+			expected: time.Minute,
+			history:  history,
+			want:     " (~1m0s)",
+		},
+		{
+			name:     "too few runs to flag",
+			expected: 30 * time.Second,
+			history:  history[:slowMinRuns-1],
+			want:     " (~30s)",
+		},
+		{
+			name:     "consistently slower",
+			expected: 30 * time.Second,
+			history:  history,
+			want:     " (~30s) ⚠️ median 1m30s over 3 runs",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
+
+func TestEta(t *testing.T) {
+	pcd := NewProcedure(ProcedureOpts{})
+	pcd.AddStep(&Step{Title: "one", ExpectedDuration: time.Minute})
+	pcd.AddStep(&Step{Title: "two", ExpectedDuration: 2 * time.Minute})
+	pcd.AddStep(&Step{Title: "three"})
+	pcd.AddStep(&Step{Title: "four", ExpectedDuration: 4 * time.Minute})
+	pcd.run = newRunRecord("pcd", pcd.steps)
+	pcd.run.Steps[3].Status = StatusSkipped
+	pcd.stepIdx = 1
+
+	eta, unknown := pcd.eta()
+
+	qt.Assert(t, qt.Equals(eta, 2*time.Minute))
+	qt.Assert(t, qt.Equals(unknown, 1))
+}
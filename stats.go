@@ -0,0 +1,114 @@
+package otium
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// stepStats are the statistics of one step across many run records.
+type stepStats struct {
+	N        int
+	Title    string
+	Manual   bool
+	Runs     int // How many times the step has been done.
+	Skipped  int
+	Attempts int // How many times Run has been called.
+	Failures int // How many calls to Run failed.
+	Median   time.Duration
+	Total    time.Duration
+}
+
+// failureRate returns the ratio of failed attempts, or -1 if the step has
+// never been attempted.
+func (st stepStats) failureRate() float64 {
+	if st.Attempts == 0 {
+		return -1
+	}
+	return float64(st.Failures) / float64(st.Attempts)
+}
+
+// computeStats returns the statistics of steps, according to runs. A step
+// is matched by Title, since the same procedure can change over time.
+func computeStats(runs []RunRecord, steps []*Step) []stepStats {
+	stats := make([]stepStats, len(steps))
+	for i, step := range steps {
+		st := stepStats{N: i + 1, Title: step.Title, Manual: step.Run == nil}
+		var durations []time.Duration
+		for _, run := range runs {
+			for _, rec := range run.Steps {
+				if rec.Title != step.Title {
+					continue
+				}
+				st.Attempts += rec.Attempts
+				switch rec.Status {
+				case StatusDone, StatusRetried:
+					st.Runs++
+					if rec.Attempts > 1 {
+						st.Failures += rec.Attempts - 1
+					}
+					durations = append(durations, rec.Duration())
+					st.Total += rec.Duration()
+				case StatusFailed:
+					st.Failures += rec.Attempts
+				case StatusSkipped:
+					st.Skipped++
+				}
+			}
+		}
+		st.Median = median(durations)
+		stats[i] = st
+	}
+	return stats
+}
+
+// automationCandidates returns the manual steps of stats, ranked by the
+// total human time spent on them, most expensive first.
+func automationCandidates(stats []stepStats) []stepStats {
+	var candidates []stepStats
+	for _, st := range stats {
+		if st.Manual && st.Runs > 0 {
+			candidates = append(candidates, st)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Total > candidates[j].Total
+	})
+	return candidates
+}
+
+func writeStats(wr io.Writer, runCount int, stats []stepStats) {
+	fmt.Fprintf(wr, "\n## Statistics over %d runs\n\n", runCount)
+	fmt.Fprintf(wr, "| %2s | %-40s | %-9s | %4s | %7s | %8s | %8s |\n",
+		"#", "Step", "Type", "Runs", "Skipped", "Failures", "Median")
+	fmt.Fprintf(wr, "|----|%s|-----------|------|---------|----------|----------|\n",
+		strings.Repeat("-", 42))
+	for _, st := range stats {
+		kind := "automated"
+		if st.Manual {
+			kind = "manual"
+		}
+		failures := "-"
+		if rate := st.failureRate(); rate >= 0 {
+			failures = fmt.Sprintf("%.0f%%", 100*rate)
+		}
+		fmt.Fprintf(wr, "| %2d | %-40s | %-9s | %4d | %7d | %8s | %8s |\n",
+			st.N, st.Title, kind, st.Runs, st.Skipped, failures,
+			formatDuration(st.Median))
+	}
+
+	fmt.Fprintf(wr, "\n### Candidates for automation\n\n")
+	candidates := automationCandidates(stats)
+	if len(candidates) == 0 {
+		fmt.Fprintf(wr, "no manual steps done yet\n\n")
+		return
+	}
+	for i, st := range candidates {
+		fmt.Fprintf(wr, "%d. step %d. %s: total %s, median %s over %d runs\n",
+			i+1, st.N, st.Title, formatDuration(st.Total),
+			formatDuration(st.Median), st.Runs)
+	}
+	fmt.Fprintln(wr)
+}
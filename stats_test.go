@@ -0,0 +1,69 @@
+package otium
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/go-quicktest/qt"
+)
+
+func TestComputeStats(t *testing.T) {
+	steps := []*Step{
+		{Title: "Red fruits"},
+		{Title: "Blue fruits", Run: func(bag Bag, uctx any) error { return nil }},
+		{Title: "Green fruits"},
+	}
+	run1 := newTestRunRecord()
+	run2 := newTestRunRecord()
+	run2.Steps[0].End = run2.Steps[0].Start.Add(30 * time.Second)
+	run2.Steps[1].Status = StatusFailed
+	run2.Steps[1].Attempts = 1
+
+	have := computeStats([]RunRecord{run1, run2}, steps)
+
+	qt.Assert(t, qt.DeepEquals(have, []stepStats{
+		{
+			N: 1, Title: "Red fruits", Manual: true, Runs: 2,
+			Median: time.Minute, Total: 2 * time.Minute,
+		},
+		{
+			N: 2, Title: "Blue fruits", Runs: 1, Attempts: 3, Failures: 2,
+			Median: time.Minute, Total: time.Minute,
+		},
+		{N: 3, Title: "Green fruits", Manual: true, Skipped: 2},
+	}))
+}
+
+func TestWriteStats(t *testing.T) {
+	stats := []stepStats{
+		{N: 1, Title: "Red fruits", Manual: true, Runs: 2,
+			Median: time.Minute, Total: 2 * time.Minute},
+		{N: 2, Title: "Blue fruits", Runs: 1, Attempts: 4, Failures: 1,
+			Median: time.Minute, Total: time.Minute},
+		{N: 3, Title: "Green fruits", Manual: true, Runs: 3,
+			Median: 5 * time.Minute, Total: 15 * time.Minute},
+		{N: 4, Title: "Yellow fruits", Manual: true, Skipped: 2},
+	}
+	var buf bytes.Buffer
+
+	writeStats(&buf, 3, stats)
+
+	want := `
+## Statistics over 3 runs
+
+|  # | Step                                     | Type      | Runs | Skipped | Failures |   Median |
+|----|------------------------------------------|-----------|------|---------|----------|----------|
+|  1 | Red fruits                               | manual    |    2 |       0 |        - |     1m0s |
+|  2 | Blue fruits                              | automated |    1 |       0 |      25% |     1m0s |
+|  3 | Green fruits                             | manual    |    3 |       0 |        - |     5m0s |
+|  4 | Yellow fruits                            | manual    |    0 |       2 |        - |        - |
+
+### Candidates for automation
+
+1. step 3. Green fruits: total 15m0s, median 5m0s over 3 runs
+2. step 1. Red fruits: total 2m0s, median 1m0s over 2 runs
+
+`
+	qt.Assert(t, qt.Equals(buf.String(), want))
+}
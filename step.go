@@ -1,9 +1,11 @@
 package otium
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 )
 
 // Step is part of a [Procedure]. See [Procedure.Add].
@@ -17,6 +19,10 @@ type Step struct {
 	Desc string
 	// Vars are the new variables needed by the step.
 	Vars []Variable
+	// Checklist is the optional ordered list of items that the human must
+	// acknowledge one by one (or explicitly waive) before the step is
+	// considered done. Meant for manual steps.
+	Checklist []string
 	// Run is the optional automation of the step. If the step is manual,
 	// leave Run unset. When called, bag will contain all the key/value pairs
 	// set by the previous steps and uctx, if not nil, will point to the user
@@ -26,6 +32,28 @@ type Step struct {
 	// For the user context, see also [ProcedureOpts.PreFlight] and
 	// examples/usercontext.
 	Run func(bag Bag, uctx any) error
+	// Verify is the optional verification of a manual step. If set, it is
+	// called after the human has acknowledged the step (and its Checklist),
+	// with the same bag and uctx as Run. If it returns an error, the human
+	// is kept on the step until Verify succeeds or the human overrides it,
+	// giving a reason. Verify cannot be set together with Run.
+	Verify func(ctx context.Context, bag Bag, uctx any) error
+	// DryRun is the optional description of what Run would do, shown instead
+	// of calling Run when the procedure is invoked with --dry-run. It is
+	// called with the same bag and uctx as Run and should not have side
+	// effects. DryRun requires Run.
+	DryRun func(bag Bag, uctx any) (string, error)
+	// FakeRun is the optional replacement of Run when the step is rehearsed
+	// (see flags --rehearsal and --rehearse), for example to train new
+	// operators without touching the real systems. If a rehearsed step does
+	// not set FakeRun, the human performs it manually, following Desc, and
+	// the step is recorded as skipped. FakeRun requires Run.
+	FakeRun func(bag Bag, uctx any) error
+	// ExpectedDuration is the optional estimate of how long the step takes.
+	// It is used to show the ETA of the remaining steps in the table of
+	// contents and to flag the steps that, according to the run records,
+	// consistently take much longer than expected.
+	ExpectedDuration time.Duration
 }
 
 // validate checks that step is valid. Meant to be called by Procedure.Exec.
@@ -38,6 +66,25 @@ func (step *Step) validate(stepN int) error {
 	if step.Title == "" {
 		errs = append(errs, fmt.Errorf("step (%d) has empty Title", stepN))
 	}
+	if step.Run != nil && step.Verify != nil {
+		errs = append(errs,
+			fmt.Errorf("step (%d) has both Run and Verify; want at most one", stepN))
+	}
+	if step.DryRun != nil && step.Run == nil {
+		errs = append(errs,
+			fmt.Errorf("step (%d) has DryRun but not Run", stepN))
+	}
+	if step.FakeRun != nil && step.Run == nil {
+		errs = append(errs,
+			fmt.Errorf("step (%d) has FakeRun but not Run", stepN))
+	}
+	for i, item := range step.Checklist {
+		step.Checklist[i] = strings.TrimSpace(item)
+		if step.Checklist[i] == "" {
+			errs = append(errs,
+				fmt.Errorf("step (%d) has empty Checklist item %d", stepN, i+1))
+		}
+	}
 
 	return errors.Join(errs...)
 }
@@ -3,6 +3,9 @@ package otium
 import (
 	"io"
 	"text/template"
+	"text/template/parse"
+
+	"golang.org/x/exp/slices"
 )
 
 func renderTemplate(wr io.Writer, text string, bag map[string]Variable) error {
@@ -23,3 +26,131 @@ func renderTemplate(wr io.Writer, text string, bag map[string]Variable) error {
 
 	return nil
 }
+
+// renderPreview is like renderTemplate, but renders each action printing
+// a field that is not set in bag, such as {{.name}}, as <unset:name>. Since
+// an unset field is still the empty string, the conditions ({{if .name}},
+// ...) take the same branches as when running the step.
+func renderPreview(wr io.Writer, text string, bag map[string]Variable) error {
+	tmpl, err := template.New("description").Parse(text)
+	if err != nil {
+		return err
+	}
+	fields, err := templateFields(text)
+	if err != nil {
+		return err
+	}
+
+	m := make(map[string]string, len(fields))
+	unset := make(map[string]bool)
+	for _, k := range fields {
+		v := bag[k]
+		m[k] = v.val
+		unset[k] = !v.set
+	}
+	if tmpl.Tree != nil {
+		markUnset(tmpl.Tree.Root, unset)
+	}
+
+	return tmpl.Execute(wr, m)
+}
+
+// markUnset replaces, in the template tree rooted at node, each action
+// printing an unset field with the text <unset:name>. It does not descend
+// into the body of {{range}} and {{with}}, where dot is no longer the bag.
+func markUnset(node parse.Node, unset map[string]bool) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+		for i, n := range node.Nodes {
+			if action, ok := n.(*parse.ActionNode); ok {
+				if name := printedField(action); unset[name] {
+					node.Nodes[i] = &parse.TextNode{
+						NodeType: parse.NodeText,
+						Pos:      action.Pos,
+						Text:     []byte("<unset:" + name + ">"),
+					}
+				}
+				continue
+			}
+			markUnset(n, unset)
+		}
+	case *parse.IfNode:
+		markUnset(node.List, unset)
+		markUnset(node.ElseList, unset)
+	case *parse.RangeNode:
+		markUnset(node.ElseList, unset)
+	case *parse.WithNode:
+		markUnset(node.ElseList, unset)
+	}
+}
+
+// printedField returns the name of the field printed by action if action is
+// just {{.name}}, the empty string otherwise.
+func printedField(action *parse.ActionNode) string {
+	pipe := action.Pipe
+	if pipe == nil || len(pipe.Decl) > 0 || len(pipe.Cmds) != 1 ||
+		len(pipe.Cmds[0].Args) != 1 {
+		return ""
+	}
+	field, ok := pipe.Cmds[0].Args[0].(*parse.FieldNode)
+	if !ok || len(field.Ident) != 1 {
+		return ""
+	}
+	return field.Ident[0]
+}
+
+// templateFields returns the names of the fields (for example, "name" for
+// {{.name}}) referenced by text, in order of first appearance.
+func templateFields(text string) ([]string, error) {
+	tmpl, err := template.New("description").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	var fields []string
+	if tmpl.Tree != nil {
+		walkFields(tmpl.Tree.Root, &fields)
+	}
+	return fields, nil
+}
+
+func walkFields(node parse.Node, fields *[]string) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+		for _, n := range node.Nodes {
+			walkFields(n, fields)
+		}
+	case *parse.ActionNode:
+		walkFields(node.Pipe, fields)
+	case *parse.PipeNode:
+		if node == nil {
+			return
+		}
+		for _, cmd := range node.Cmds {
+			walkFields(cmd, fields)
+		}
+	case *parse.CommandNode:
+		for _, arg := range node.Args {
+			walkFields(arg, fields)
+		}
+	case *parse.BranchNode:
+		walkFields(node.Pipe, fields)
+		walkFields(node.List, fields)
+		walkFields(node.ElseList, fields)
+	case *parse.IfNode:
+		walkFields(&node.BranchNode, fields)
+	case *parse.RangeNode:
+		walkFields(&node.BranchNode, fields)
+	case *parse.WithNode:
+		walkFields(&node.BranchNode, fields)
+	case *parse.FieldNode:
+		if !slices.Contains(*fields, node.Ident[0]) {
+			*fields = append(*fields, node.Ident[0])
+		}
+	}
+}
@@ -3,6 +3,7 @@ package otium
 import (
 	"bytes"
 	"testing"
+	"text/template"
 
 	"github.com/go-quicktest/qt"
 )
@@ -52,3 +53,129 @@ func TestRender(t *testing.T) {
 		})
 	}
 }
+
+func TestTemplateFields(t *testing.T) {
+	type testCase struct {
+		name string
+		text string
+		want []string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		have, err := templateFields(tc.text)
+		qt.Assert(t, qt.IsNil(err))
+		qt.Assert(t, qt.DeepEquals(have, tc.want))
+	}
+
+	testCases := []testCase{
+		{
+			name: "no fields",
+			text: "Hello!",
+			want: nil,
+		},
+		{
+			name: "fields in order of appearance, no duplicates",
+			text: "{{.b}} {{.a}} {{.b}}",
+			want: []string{"b", "a"},
+		},
+		{
+			name: "fields in actions",
+			text: "{{if .a}}{{.b}}{{else}}{{.c}}{{end}}{{with .d}}{{end}}{{range .e}}{{end}}",
+			want: []string{"a", "b", "c", "d", "e"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestRenderPreview(t *testing.T) {
+	bag := map[string]Variable{
+		"name":  {val: "Joe", set: true},
+		"fruit": {},
+	}
+	var buf bytes.Buffer
+
+	err := renderPreview(&buf, "Hello {{.name}}, have a {{.fruit}} or {{.drink}}!", bag)
+
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(buf.String(),
+		"Hello Joe, have a <unset:fruit> or <unset:drink>!"))
+}
+
+func TestMarkUnsetKeepsTheFieldsOfANewDot(t *testing.T) {
+	type testCase struct {
+		name string
+		text string
+		want string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		tmpl, err := template.New("description").Parse(tc.text)
+		qt.Assert(t, qt.IsNil(err))
+
+		markUnset(tmpl.Tree.Root, map[string]bool{"items": true, "x": true})
+
+		qt.Assert(t, qt.Equals(tmpl.Tree.Root.String(), tc.want))
+	}
+
+	testCases := []testCase{
+		{
+			name: "range",
+			text: "{{range .items}}{{.x}}{{else}}{{.x}}{{end}}",
+			want: "{{range .items}}{{.x}}{{else}}<unset:x>{{end}}",
+		},
+		{
+			name: "with",
+			text: "{{with .items}}{{.x}}{{else}}{{.x}}{{end}}",
+			want: "{{with .items}}{{.x}}{{else}}<unset:x>{{end}}",
+		},
+		{
+			name: "if",
+			text: "{{if .items}}{{.x}}{{end}}",
+			want: "{{if .items}}<unset:x>{{end}}",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
+
+func TestRenderPreviewKeepsConditions(t *testing.T) {
+	type testCase struct {
+		name string
+		bag  map[string]Variable
+		want string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		var buf bytes.Buffer
+
+		err := renderPreview(&buf,
+			"{{if .fruit}}Eat {{.fruit}}{{else}}No {{.fruit}} fruit{{end}}, {{.name}}", tc.bag)
+
+		qt.Assert(t, qt.IsNil(err))
+		qt.Assert(t, qt.Equals(buf.String(), tc.want))
+	}
+
+	testCases := []testCase{
+		{
+			name: "unset takes the else branch",
+			bag:  map[string]Variable{"name": {val: "Joe", set: true}},
+			want: "No <unset:fruit> fruit, Joe",
+		},
+		{
+			name: "set takes the if branch",
+			bag:  map[string]Variable{"fruit": {val: "mango", set: true}},
+			want: "Eat mango, <unset:name>",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
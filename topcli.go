@@ -2,7 +2,7 @@ package otium
 
 import (
 	"fmt"
-	"io"
+	"strings"
 
 	"github.com/alecthomas/kong"
 )
@@ -21,12 +21,19 @@ type topcli struct {
 	Repl      replCmd      `cmd:"" help:"Show help for the REPL."`
 	List      listCmd      `cmd:"" help:"Show the list of steps."`
 	Next      nextCmd      `cmd:"" help:"Run the next step."`
+	Show      showCmd      `cmd:"" help:"Preview a step (by default, the next one) without running it."`
+	Skip      skipCmd      `cmd:"" help:"Skip steps (by default, the next one)."`
+	Stats     statsCmd     `cmd:"" help:"Show statistics of the previous runs, to decide what to automate next."`
+	Preflight preflightCmd `cmd:"" help:"Run the pre-flight checks again."`
 	Quit      quitCmd      `cmd:"" help:"Quit the program."`
 	Variables variablesCmd `cmd:"" help:"List the variables."`
+	Set       setCmd       `cmd:"" help:"Set a variable."`
+	Unset     unsetCmd     `cmd:"" help:"Unset a variable."`
+	Edit      editCmd      `cmd:"" help:"Edit a variable interactively."`
 }
 
 type helpCmd struct {
-	Command []string `arg:"" optional:"" help:"Show help on command."`
+	Command []string `arg:"" optional:"" help:"Show help on command." complete:"commands"`
 }
 
 func (h *helpCmd) Run(realCtx *kong.Context) error {
@@ -79,11 +86,40 @@ func (n *nextCmd) Run(bind *bind) error {
 	return cmdNext(bind.pcd)
 }
 
+type showCmd struct {
+	Step int `arg:"" optional:"" help:"Step to preview." complete:"steps"`
+}
+
+func (s *showCmd) Run(bind *bind) error {
+	return cmdShow(bind.pcd, s.Step)
+}
+
+type skipCmd struct {
+	Steps []int `arg:"" optional:"" help:"Steps to skip." complete:"steps"`
+}
+
+func (s *skipCmd) Run(bind *bind) error {
+	return cmdSkip(bind.pcd, s.Steps)
+}
+
+type statsCmd struct {
+	Dir string `arg:"" optional:"" help:"Directory containing the run records (default: the runs directory of this procedure)."`
+}
+
+func (s *statsCmd) Run(bind *bind) error {
+	return cmdStats(bind.pcd, s.Dir)
+}
+
+type preflightCmd struct{}
+
+func (p *preflightCmd) Run(bind *bind) error {
+	return cmdPreflight(bind.pcd)
+}
+
 type quitCmd struct{}
 
 func (q *quitCmd) Run(bind *bind) error {
-	// FIXME HACK USE PROPER ErrQuit sentinel instead!!!
-	return io.EOF
+	return cmdQuit(bind.pcd)
 }
 
 type variablesCmd struct{}
@@ -92,3 +128,43 @@ func (q *variablesCmd) Run(bind *bind) error {
 	cmdVariables(bind.pcd)
 	return nil
 }
+
+type setCmd struct {
+	Key   string `arg:"" help:"Name of the variable." complete:"vars"`
+	Value string `arg:"" help:"Value of the variable." complete:"values"`
+}
+
+func (s *setCmd) Run(bind *bind) error {
+	return cmdSet(bind.pcd, s.Key, s.Value)
+}
+
+type unsetCmd struct {
+	Key string `arg:"" help:"Name of the variable." complete:"vars"`
+}
+
+func (u *unsetCmd) Run(bind *bind) error {
+	return cmdUnset(bind.pcd, u.Key)
+}
+
+type editCmd struct {
+	Key string `arg:"" help:"Name of the variable." complete:"vars"`
+}
+
+func (e *editCmd) Run(bind *bind) error {
+	return cmdEdit(bind.pcd, e.Key)
+}
+
+// customCmd adapts a procedure-specific [Command] to kong.
+type customCmd struct {
+	Args []string `arg:"" optional:"" help:"Arguments of the command."`
+	cmd  Command
+}
+
+func (c *customCmd) Run(bind *bind) error {
+	if len(c.cmd.Args) > 0 && len(c.Args) != len(c.cmd.Args) {
+		return fmt.Errorf("%s: want %d arguments (<%s>); have %d",
+			c.cmd.Name, len(c.cmd.Args), strings.Join(c.cmd.Args, "> <"),
+			len(c.Args))
+	}
+	return c.cmd.Run(bind.pcd.out, c.Args, bind.pcd.bag, bind.pcd.uctx)
+}
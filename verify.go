@@ -0,0 +1,103 @@
+package otium
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// verify calls the Verify function of step, if set. If Verify fails, verify
+// shows the error to the user and keeps asking until either a retry of
+// Verify succeeds or the user overrides the verification, giving a reason
+// (stored in rec). If Verify succeeds, the reason of a previous override is
+// cleared, since it no longer applies.
+// verify returns errBack if the user wants to go back to the top level REPL.
+func verify(ctx context.Context, step *Step, rec *StepRecord, bag Bag, uctx any,
+	term Prompter, out io.Writer,
+) error {
+	if step.Verify == nil {
+		return nil
+	}
+
+	for {
+		err := step.Verify(ctx, bag, uctx)
+		if err == nil {
+			rec.Override = ""
+			return nil
+		}
+		fmt.Fprintf(out, "(verify) Verification failed: %s\n", err)
+
+		retry, err := askOverride(rec, term, out)
+		if err != nil {
+			return err
+		}
+		if !retry {
+			return nil
+		}
+	}
+}
+
+// askOverride asks the user what to do after a failed verification. It
+// returns true if the user wants to retry the verification, false if the
+// user has overridden it (the reason is stored in rec).
+func askOverride(rec *StepRecord, term Prompter, out io.Writer) (bool, error) {
+	term.SetCompleter(makeVerifyCompleter())
+
+	for {
+		fmt.Fprintf(out, "(verify) Fix the problem and retry, or override <reason>; '?' for help\n")
+		line, err := term.Prompt("(verify)>> ")
+		if errors.Is(err, ErrPromptAborted) {
+			// Ctrl-C behaves as the back command.
+			return false, errBack
+		}
+		if err != nil {
+			return false, fmt.Errorf("reading the verification: %w", err)
+		}
+
+		tokens := strings.Fields(line)
+		if len(tokens) == 0 {
+			continue
+		}
+		switch tokens[0] {
+		case "help", "?":
+			fmt.Fprint(out, `
+  retry                  run the verification again
+  override <reason>      consider the step done, explaining why
+  back                   go back to the top level REPL
+
+`)
+			continue
+		case "back":
+			return false, errBack
+		case "retry":
+			return true, nil
+		case "override":
+			if len(tokens) < 2 {
+				fmt.Fprintf(out, "want: override <reason>; have: %q\n", tokens)
+				continue
+			}
+			rec.Override = strings.Join(tokens[1:], " ")
+			return false, nil
+		default:
+			fmt.Fprintf(out, "invalid: %q\n", line)
+			continue
+		}
+	}
+}
+
+// makeVerifyCompleter returns a Completer for the verify sub REPL.
+func makeVerifyCompleter() Completer {
+	return func(line string) []string {
+		commands := []string{"help", "?", "back", "retry", "override"}
+		completions := make([]string, 0, len(commands))
+		line = strings.ToLower(line)
+		for _, cmd := range commands {
+			if strings.HasPrefix(cmd, line) {
+				completions = append(completions, cmd)
+			}
+		}
+		return completions
+	}
+}
@@ -0,0 +1,115 @@
+package otium
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-quicktest/qt"
+
+	"github.com/marco-m/otium/expect"
+)
+
+func TestVerifyNotSet(t *testing.T) {
+	err := verify(context.Background(), &Step{}, &StepRecord{}, NewBag(), nil, nil, nil)
+
+	qt.Assert(t, qt.IsNil(err))
+}
+
+func TestVerifySuccess(t *testing.T) {
+	step := &Step{
+		Verify: func(ctx context.Context, bag Bag, uctx any) error {
+			return nil
+		},
+	}
+
+	err := verify(context.Background(), step, &StepRecord{}, NewBag(), nil, nil, nil)
+
+	qt.Assert(t, qt.IsNil(err))
+}
+
+func TestVerifySuccessClearsPreviousOverride(t *testing.T) {
+	step := &Step{
+		Verify: func(ctx context.Context, bag Bag, uctx any) error {
+			return nil
+		},
+	}
+	rec := &StepRecord{Override: "TTL not yet expired"}
+
+	err := verify(context.Background(), step, rec, NewBag(), nil, nil, nil)
+
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(rec.Override, ""))
+}
+
+func TestVerifyFailureThenRetrySuccess(t *testing.T) {
+	stdin, stdout, exp := expect.New(100*time.Millisecond, expect.MatchMaxDef)
+	calls := 0
+	step := &Step{
+		Verify: func(ctx context.Context, bag Bag, uctx any) error {
+			calls++
+			if calls == 1 {
+				return errors.New("file not found")
+			}
+			return nil
+		},
+	}
+	rec := &StepRecord{}
+
+	term := NewLinePrompter(stdin, stdout)
+
+	asyncErr := make(chan error)
+	go func() {
+		asyncErr <- verify(context.Background(), step, rec, NewBag(), nil, term, stdout)
+	}()
+
+	m, err := exp.ExpectMatch(expect.Literal("(verify)>> "))
+	have := m.Upto()
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(have, `(verify) Verification failed: file not found
+(verify) Fix the problem and retry, or override <reason>; '?' for help
+(verify)>> `))
+
+	err = exp.Send("retry\n")
+	qt.Assert(t, qt.IsNil(err))
+
+	err = <-asyncErr
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(calls, 2))
+	qt.Assert(t, qt.Equals(rec.Override, ""))
+}
+
+func TestVerifyFailureThenOverride(t *testing.T) {
+	stdin, stdout, exp := expect.New(100*time.Millisecond, expect.MatchMaxDef)
+	step := &Step{
+		Verify: func(ctx context.Context, bag Bag, uctx any) error {
+			return errors.New("DNS record not found")
+		},
+	}
+	rec := &StepRecord{}
+
+	term := NewLinePrompter(stdin, stdout)
+
+	asyncErr := make(chan error)
+	go func() {
+		asyncErr <- verify(context.Background(), step, rec, NewBag(), nil, term, stdout)
+	}()
+
+	_, err := exp.ExpectMatch(expect.Literal("(verify)>> "))
+	qt.Assert(t, qt.IsNil(err))
+
+	err = exp.Send("override\n")
+	qt.Assert(t, qt.IsNil(err))
+	have, err := exp.Expect(`(?s)want: .*\(verify\)>> `)
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.StringContains(have,
+		"want: override <reason>; have: [\"override\"]\n"))
+
+	err = exp.Send("override TTL not yet expired\n")
+	qt.Assert(t, qt.IsNil(err))
+
+	err = <-asyncErr
+	qt.Assert(t, qt.IsNil(err))
+	qt.Assert(t, qt.Equals(rec.Override, "TTL not yet expired"))
+}